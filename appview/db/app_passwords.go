@@ -0,0 +1,75 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// AppPassword is a token that authenticates git operations over HTTP
+// on behalf of a DID. Only a hash of the token is stored.
+type AppPassword struct {
+	Did     string
+	Name    string
+	Created time.Time
+}
+
+func hashAppPassword(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateAppPassword creates a new app password for did and returns the
+// plaintext token. The token cannot be recovered afterwards.
+func GenerateAppPassword(e Execer, did, name string) (string, error) {
+	token := genSecret()
+	_, err := e.Exec(
+		`insert into app_passwords (did, name, token_hash) values (?, ?, ?)`,
+		did, name, hashAppPassword(token),
+	)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func RemoveAppPassword(e Execer, did, name string) error {
+	_, err := e.Exec(`delete from app_passwords where did = ? and name = ?`, did, name)
+	return err
+}
+
+func GetAppPasswords(e Execer, did string) ([]AppPassword, error) {
+	var passwords []AppPassword
+
+	rows, err := e.Query(`select did, name, created from app_passwords where did = ? order by created desc`, did)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p AppPassword
+		var createdAt string
+		if err := rows.Scan(&p.Did, &p.Name, &createdAt); err != nil {
+			return nil, err
+		}
+		p.Created, _ = time.Parse(time.RFC3339, createdAt)
+		passwords = append(passwords, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return passwords, nil
+}
+
+// CheckAppPassword reports whether token is a valid app password for did.
+func CheckAppPassword(e Execer, did, token string) (bool, error) {
+	var exists bool
+	err := e.QueryRow(
+		`select exists (select 1 from app_passwords where did = ? and token_hash = ?)`,
+		did, hashAppPassword(token),
+	).Scan(&exists)
+	return exists, err
+}
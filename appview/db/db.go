@@ -24,7 +24,9 @@ type Execer interface {
 }
 
 func Make(dbPath string) (*DB, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	// pragmas only stick to the connection they run on, and deletes rely on
+	// foreign keys cascading on every connection in the pool
+	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
 	if err != nil {
 		return nil, err
 	}
@@ -123,6 +125,85 @@ func Make(dbPath string) (*DB, error) {
 			unique(starred_by_did, repo_at)
 		);
 
+		create table if not exists pulls (
+			id integer primary key autoincrement,
+			owner_did text not null,
+			repo_at text not null,
+			pull_id integer not null,
+			title text not null,
+			body text not null,
+			patch text not null,
+			target_branch text not null,
+			state integer not null default 0,
+			created text not null default (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+			pull_at text,
+			unique(repo_at, pull_id),
+			foreign key (repo_at) references repos(at_uri) on delete cascade
+		);
+		create table if not exists pull_comments (
+			id integer primary key autoincrement,
+			owner_did text not null,
+			pull_id integer not null,
+			repo_at text not null,
+			comment_id integer not null,
+			comment_at text not null,
+			body text not null,
+			created text not null default (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+			unique(repo_at, pull_id, comment_id),
+			foreign key (repo_at, pull_id) references pulls(repo_at, pull_id) on delete cascade
+		);
+		create table if not exists repo_pull_seqs (
+			repo_at text primary key,
+			next_pull_id integer not null default 1
+		);
+
+		create table if not exists app_passwords (
+			id integer primary key autoincrement,
+			did text not null,
+			name text not null,
+			token_hash text not null unique,
+			created text not null default (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+			unique(did, name)
+		);
+
+		create table if not exists webhooks (
+			id integer primary key autoincrement,
+			repo_at text not null,
+			url text not null,
+			secret text not null,
+			events text not null,
+			active integer not null default 1,
+			created text not null default (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+			foreign key (repo_at) references repos(at_uri) on delete cascade
+		);
+		create table if not exists webhook_deliveries (
+			id integer primary key autoincrement,
+			webhook_id integer not null,
+			event text not null,
+			payload text not null,
+			status integer not null default 0,
+			attempts integer not null default 0,
+			next_attempt text not null default (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+			response_code integer not null default 0,
+			error text not null default '',
+			created text not null default (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+			foreign key (webhook_id) references webhooks(id) on delete cascade
+		);
+		create table if not exists repo_redirects (
+			did text not null,
+			name text not null,
+			repo_at text not null,
+			created text not null default (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+			primary key (did, name)
+		);
+		create table if not exists repo_transfers (
+			repo_at text primary key,
+			from_did text not null,
+			to_did text not null,
+			created text not null default (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+			foreign key (repo_at) references repos(at_uri) on delete cascade
+		);
+
 		create table if not exists migrations (
 			id integer primary key autoincrement,
 			name text unique
@@ -140,6 +221,36 @@ func Make(dbPath string) (*DB, error) {
 		return nil
 	})
 
+	runMigration(db, "add-source-to-repos", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			alter table repos add column source text;
+		`)
+		return err
+	})
+
+	runMigration(db, "add-role-to-collaborators", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			alter table collaborators add column role text not null default 'write';
+		`)
+		return err
+	})
+
+	// rows left behind by repos deleted on connections without foreign keys
+	runMigration(db, "remove-rows-of-deleted-repos", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			delete from collaborators where repo not in (select id from repos);
+			delete from issues where repo_at not in (select at_uri from repos);
+			delete from comments where (repo_at, issue_id) not in (select repo_at, issue_id from issues);
+			delete from pulls where repo_at not in (select at_uri from repos);
+			delete from pull_comments where (repo_at, pull_id) not in (select repo_at, pull_id from pulls);
+			delete from stars where repo_at not in (select at_uri from repos);
+			delete from webhooks where repo_at not in (select at_uri from repos);
+			delete from webhook_deliveries where webhook_id not in (select id from webhooks);
+			delete from repo_transfers where repo_at not in (select at_uri from repos);
+		`)
+		return err
+	})
+
 	return &DB{db}, nil
 }
 
@@ -0,0 +1,298 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+type PullState int
+
+const (
+	PullOpen PullState = iota
+	PullClosed
+	PullMerged
+)
+
+func (p PullState) String() string {
+	switch p {
+	case PullOpen:
+		return "open"
+	case PullClosed:
+		return "closed"
+	case PullMerged:
+		return "merged"
+	}
+	return "unknown"
+}
+
+type Pull struct {
+	RepoAt       syntax.ATURI
+	OwnerDid     string
+	PullId       int
+	PullAt       string
+	TargetBranch string
+	Patch        string
+	Title        string
+	Body         string
+	State        PullState
+	Created      *time.Time
+	Metadata     *PullMetadata
+}
+
+type PullMetadata struct {
+	CommentCount int
+}
+
+type PullComment struct {
+	OwnerDid  string
+	RepoAt    syntax.ATURI
+	CommentAt string
+	Pull      int
+	CommentId int
+	Body      string
+	Created   *time.Time
+}
+
+func NewPull(tx *sql.Tx, pull *Pull) error {
+	defer tx.Rollback()
+
+	_, err := tx.Exec(`
+		insert or ignore into repo_pull_seqs (repo_at, next_pull_id)
+		values (?, 1)
+		`, pull.RepoAt)
+	if err != nil {
+		return err
+	}
+
+	var nextId int
+	err = tx.QueryRow(`
+		update repo_pull_seqs
+		set next_pull_id = next_pull_id + 1
+		where repo_at = ?
+		returning next_pull_id - 1
+		`, pull.RepoAt).Scan(&nextId)
+	if err != nil {
+		return err
+	}
+
+	pull.PullId = nextId
+
+	_, err = tx.Exec(`
+		insert into pulls (repo_at, owner_did, pull_id, title, body, patch, target_branch)
+		values (?, ?, ?, ?, ?, ?, ?)
+	`, pull.RepoAt, pull.OwnerDid, pull.PullId, pull.Title, pull.Body, pull.Patch, pull.TargetBranch)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func SetPullAt(e Execer, repoAt syntax.ATURI, pullId int, pullAt string) error {
+	_, err := e.Exec(`update pulls set pull_at = ? where repo_at = ? and pull_id = ?`, pullAt, repoAt, pullId)
+	return err
+}
+
+func GetPullAt(e Execer, repoAt syntax.ATURI, pullId int) (string, error) {
+	var pullAt string
+	err := e.QueryRow(`select pull_at from pulls where repo_at = ? and pull_id = ?`, repoAt, pullId).Scan(&pullAt)
+	return pullAt, err
+}
+
+func GetPullId(e Execer, repoAt syntax.ATURI) (int, error) {
+	var pullId int
+	err := e.QueryRow(`select next_pull_id from repo_pull_seqs where repo_at = ?`, repoAt).Scan(&pullId)
+	return pullId - 1, err
+}
+
+func GetPulls(e Execer, repoAt syntax.ATURI, state PullState) ([]Pull, error) {
+	var pulls []Pull
+
+	rows, err := e.Query(
+		`select
+			p.owner_did,
+			p.pull_id,
+			p.created,
+			p.title,
+			p.target_branch,
+			p.state,
+			count(c.id)
+		from
+			pulls p
+		left join
+			pull_comments c on p.repo_at = c.repo_at and p.pull_id = c.pull_id
+		where
+			p.repo_at = ? and p.state = ?
+		group by
+			p.id, p.owner_did, p.pull_id, p.created, p.title, p.target_branch, p.state
+		order by
+			p.created desc`,
+		repoAt, state)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pull Pull
+		var createdAt string
+		var metadata PullMetadata
+		err := rows.Scan(&pull.OwnerDid, &pull.PullId, &createdAt, &pull.Title, &pull.TargetBranch, &pull.State, &metadata.CommentCount)
+		if err != nil {
+			return nil, err
+		}
+
+		createdTime, err := time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			return nil, err
+		}
+		pull.Created = &createdTime
+		pull.Metadata = &metadata
+
+		pulls = append(pulls, pull)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pulls, nil
+}
+
+func GetPull(e Execer, repoAt syntax.ATURI, pullId int) (*Pull, error) {
+	query := `select owner_did, pull_id, created, title, body, patch, target_branch, state, pull_at from pulls where repo_at = ? and pull_id = ?`
+	row := e.QueryRow(query, repoAt, pullId)
+
+	var pull Pull
+	var createdAt string
+	var pullAt sql.NullString
+	err := row.Scan(&pull.OwnerDid, &pull.PullId, &createdAt, &pull.Title, &pull.Body, &pull.Patch, &pull.TargetBranch, &pull.State, &pullAt)
+	if err != nil {
+		return nil, err
+	}
+
+	createdTime, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	pull.Created = &createdTime
+	pull.RepoAt = repoAt
+	pull.PullAt = pullAt.String
+
+	return &pull, nil
+}
+
+func GetPullWithComments(e Execer, repoAt syntax.ATURI, pullId int) (*Pull, []PullComment, error) {
+	pull, err := GetPull(e, repoAt, pullId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	comments, err := GetPullComments(e, repoAt, pullId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pull, comments, nil
+}
+
+func NewPullComment(e Execer, comment *PullComment) error {
+	query := `insert into pull_comments (owner_did, repo_at, comment_at, pull_id, comment_id, body) values (?, ?, ?, ?, ?, ?)`
+	_, err := e.Exec(
+		query,
+		comment.OwnerDid,
+		comment.RepoAt,
+		comment.CommentAt,
+		comment.Pull,
+		comment.CommentId,
+		comment.Body,
+	)
+	return err
+}
+
+func GetPullComments(e Execer, repoAt syntax.ATURI, pullId int) ([]PullComment, error) {
+	var comments []PullComment
+
+	rows, err := e.Query(`select owner_did, pull_id, comment_id, comment_at, body, created from pull_comments where repo_at = ? and pull_id = ? order by created asc`, repoAt, pullId)
+	if err == sql.ErrNoRows {
+		return []PullComment{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var comment PullComment
+		var createdAt string
+		err := rows.Scan(&comment.OwnerDid, &comment.Pull, &comment.CommentId, &comment.CommentAt, &comment.Body, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		createdAtTime, err := time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			return nil, err
+		}
+		comment.Created = &createdAtTime
+
+		comments = append(comments, comment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+func SetPullState(e Execer, repoAt syntax.ATURI, pullId int, state PullState) error {
+	_, err := e.Exec(`update pulls set state = ? where repo_at = ? and pull_id = ?`, state, repoAt, pullId)
+	return err
+}
+
+func ClosePull(e Execer, repoAt syntax.ATURI, pullId int) error {
+	return SetPullState(e, repoAt, pullId, PullClosed)
+}
+
+func ReopenPull(e Execer, repoAt syntax.ATURI, pullId int) error {
+	return SetPullState(e, repoAt, pullId, PullOpen)
+}
+
+func MergePull(e Execer, repoAt syntax.ATURI, pullId int) error {
+	return SetPullState(e, repoAt, pullId, PullMerged)
+}
+
+type PullCount struct {
+	Open   int
+	Closed int
+	Merged int
+}
+
+func GetPullCount(e Execer, repoAt syntax.ATURI) (PullCount, error) {
+	row := e.QueryRow(`
+		select
+			count(case when state = ? then 1 end) as open_count,
+			count(case when state = ? then 1 end) as closed_count,
+			count(case when state = ? then 1 end) as merged_count
+		from pulls
+		where repo_at = ?`,
+		PullOpen,
+		PullClosed,
+		PullMerged,
+		repoAt,
+	)
+
+	var count PullCount
+	if err := row.Scan(&count.Open, &count.Closed, &count.Merged); err != nil {
+		return PullCount{0, 0, 0}, err
+	}
+
+	return count, nil
+}
@@ -13,6 +13,9 @@ type Repo struct {
 	Created     time.Time
 	AtUri       string
 	Description string
+
+	// at-uri of the repo this one was forked from, if any
+	Source string
 }
 
 func GetAllRepos(e Execer, limit int) ([]Repo, error) {
@@ -77,11 +80,12 @@ func GetAllReposByDid(e Execer, did string) ([]Repo, error) {
 func GetRepo(e Execer, did, name string) (*Repo, error) {
 	var repo Repo
 	var nullableDescription sql.NullString
+	var nullableSource sql.NullString
 
-	row := e.QueryRow(`select did, name, knot, created, at_uri, description from repos where did = ? and name = ?`, did, name)
+	row := e.QueryRow(`select did, name, knot, created, at_uri, description, source from repos where did = ? and name = ?`, did, name)
 
 	var createdAt string
-	if err := row.Scan(&repo.Did, &repo.Name, &repo.Knot, &createdAt, &repo.AtUri, &nullableDescription); err != nil {
+	if err := row.Scan(&repo.Did, &repo.Name, &repo.Knot, &createdAt, &repo.AtUri, &nullableDescription, &nullableSource); err != nil {
 		return nil, err
 	}
 	createdAtTime, _ := time.Parse(time.RFC3339, createdAt)
@@ -93,17 +97,22 @@ func GetRepo(e Execer, did, name string) (*Repo, error) {
 		repo.Description = ""
 	}
 
+	if nullableSource.Valid {
+		repo.Source = nullableSource.String
+	}
+
 	return &repo, nil
 }
 
 func GetRepoByAtUri(e Execer, atUri string) (*Repo, error) {
 	var repo Repo
 	var nullableDescription sql.NullString
+	var nullableSource sql.NullString
 
-	row := e.QueryRow(`select did, name, knot, created, at_uri, description from repos where at_uri = ?`, atUri)
+	row := e.QueryRow(`select did, name, knot, created, at_uri, description, source from repos where at_uri = ?`, atUri)
 
 	var createdAt string
-	if err := row.Scan(&repo.Did, &repo.Name, &repo.Knot, &createdAt, &repo.AtUri, &nullableDescription); err != nil {
+	if err := row.Scan(&repo.Did, &repo.Name, &repo.Knot, &createdAt, &repo.AtUri, &nullableDescription, &nullableSource); err != nil {
 		return nil, err
 	}
 	createdAtTime, _ := time.Parse(time.RFC3339, createdAt)
@@ -115,15 +124,19 @@ func GetRepoByAtUri(e Execer, atUri string) (*Repo, error) {
 		repo.Description = ""
 	}
 
+	if nullableSource.Valid {
+		repo.Source = nullableSource.String
+	}
+
 	return &repo, nil
 }
 
 func AddRepo(e Execer, repo *Repo) error {
 	_, err := e.Exec(
 		`insert into repos 
-		(did, name, knot, rkey, at_uri, description)
-		values (?, ?, ?, ?, ?, ?)`,
-		repo.Did, repo.Name, repo.Knot, repo.Rkey, repo.AtUri, repo.Description,
+		(did, name, knot, rkey, at_uri, description, source)
+		values (?, ?, ?, ?, ?, ?, nullif(?, ''))`,
+		repo.Did, repo.Name, repo.Knot, repo.Rkey, repo.AtUri, repo.Description, repo.Source,
 	)
 	return err
 }
@@ -141,12 +154,43 @@ func AddCollaborator(e Execer, collaborator, repoOwnerDid, repoName, repoKnot st
 	return err
 }
 
+func RemoveCollaborator(e Execer, collaborator, repoOwnerDid, repoName, repoKnot string) error {
+	_, err := e.Exec(
+		`delete from collaborators
+		where did = ? and repo = (select id from repos where did = ? and name = ? and knot = ?);`,
+		collaborator, repoOwnerDid, repoName, repoKnot)
+	return err
+}
+
+func UpdateCollaboratorRole(e Execer, collaborator, repoOwnerDid, repoName, repoKnot, role string) error {
+	_, err := e.Exec(
+		`update collaborators set role = ?
+		where did = ? and repo = (select id from repos where did = ? and name = ? and knot = ?);`,
+		role, collaborator, repoOwnerDid, repoName, repoKnot)
+	return err
+}
+
+// RemoveCollaboratorFromKnot removes collaborator from every repo on knot.
+func RemoveCollaboratorFromKnot(e Execer, collaborator, knot string) error {
+	_, err := e.Exec(
+		`delete from collaborators
+		where did = ? and repo in (select id from repos where knot = ?);`,
+		collaborator, knot)
+	return err
+}
+
 func UpdateDescription(e Execer, repoAt, newDescription string) error {
 	_, err := e.Exec(
 		`update repos set description = ? where at_uri = ?`, newDescription, repoAt)
 	return err
 }
 
+func UpdateRepoKnot(e Execer, repoAt, knot string) error {
+	_, err := e.Exec(
+		`update repos set knot = ? where at_uri = ?`, knot, repoAt)
+	return err
+}
+
 func CollaboratingIn(e Execer, collaborator string) ([]Repo, error) {
 	var repos []Repo
 
@@ -175,6 +219,7 @@ func CollaboratingIn(e Execer, collaborator string) ([]Repo, error) {
 type RepoStats struct {
 	StarCount  int
 	IssueCount IssueCount
+	PullCount  PullCount
 }
 
 func scanRepo(rows *sql.Rows, did, name, knot, rkey, description *string, created *time.Time) error {
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RenameRepo renames the repo at repoAt, leaving a redirect behind.
+func RenameRepo(e Execer, repoAt, newName string) error {
+	_, err := e.Exec(
+		`insert or replace into repo_redirects (did, name, repo_at)
+		select did, name, at_uri from repos where at_uri = ?`, repoAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.Exec(`update repos set name = ? where at_uri = ?`, newName, repoAt)
+	return err
+}
+
+// TransferRepo moves the repo at oldRepoAt to a new owner, whose record for
+// it lives at newRepoAt. Everything attached to the repo by at-uri moves with
+// it, and a redirect is left behind.
+func TransferRepo(tx *sql.Tx, oldRepoAt, newDid, newName, newRkey, newRepoAt string) error {
+	// repo_at is a foreign key in most of these tables, so check them once
+	// everything has been updated
+	if _, err := tx.Exec(`pragma defer_foreign_keys = on`); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(
+		`insert or replace into repo_redirects (did, name, repo_at)
+		select did, name, at_uri from repos where at_uri = ?`, oldRepoAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`update repos set did = ?, name = ?, rkey = ?, at_uri = ? where at_uri = ?`,
+		newDid, newName, newRkey, newRepoAt, oldRepoAt)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []string{
+		"issues", "comments", "repo_issue_seqs", "stars", "pulls", "pull_comments",
+		"repo_pull_seqs", "webhooks", "repo_redirects",
+	} {
+		if _, err := tx.Exec(`update `+table+` set repo_at = ? where repo_at = ?`, newRepoAt, oldRepoAt); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`update repos set source = ? where source = ?`, newRepoAt, oldRepoAt)
+	return err
+}
+
+// GetRepoRedirect returns the at-uri of the repo that used to be at did/name.
+func GetRepoRedirect(e Execer, did, name string) (string, error) {
+	var repoAt string
+	err := e.QueryRow(`select repo_at from repo_redirects where did = ? and name = ?`, did, name).Scan(&repoAt)
+	return repoAt, err
+}
+
+type RepoTransfer struct {
+	RepoAt  string
+	FromDid string
+	ToDid   string
+	Created time.Time
+}
+
+// AddRepoTransfer offers the repo at repoAt to toDid, replacing any earlier
+// offer.
+func AddRepoTransfer(e Execer, repoAt, fromDid, toDid string) error {
+	_, err := e.Exec(
+		`insert or replace into repo_transfers (repo_at, from_did, to_did) values (?, ?, ?)`,
+		repoAt, fromDid, toDid)
+	return err
+}
+
+func GetRepoTransfer(e Execer, repoAt string) (*RepoTransfer, error) {
+	var t RepoTransfer
+	var created string
+	err := e.QueryRow(
+		`select repo_at, from_did, to_did, created from repo_transfers where repo_at = ?`, repoAt,
+	).Scan(&t.RepoAt, &t.FromDid, &t.ToDid, &created)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Created, err = time.Parse(time.RFC3339, created)
+	if err != nil {
+		t.Created = time.Now()
+	}
+
+	return &t, nil
+}
+
+func RemoveRepoTransfer(e Execer, repoAt string) error {
+	_, err := e.Exec(`delete from repo_transfers where repo_at = ?`, repoAt)
+	return err
+}
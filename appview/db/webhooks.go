@@ -0,0 +1,238 @@
+package db
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+type WebhookEvent string
+
+const (
+	WebhookPush         WebhookEvent = "push"
+	WebhookIssue        WebhookEvent = "issue"
+	WebhookIssueComment WebhookEvent = "issue_comment"
+	WebhookIssueState   WebhookEvent = "issue_state"
+	WebhookStar         WebhookEvent = "star"
+)
+
+// WebhookEvents lists every event a webhook can subscribe to.
+var WebhookEvents = []WebhookEvent{
+	WebhookPush,
+	WebhookIssue,
+	WebhookIssueComment,
+	WebhookIssueState,
+	WebhookStar,
+}
+
+type Webhook struct {
+	Id      int
+	RepoAt  syntax.ATURI
+	Url     string
+	Secret  string
+	Events  []WebhookEvent
+	Active  bool
+	Created time.Time
+}
+
+func (w Webhook) Subscribes(event WebhookEvent) bool {
+	return slices.Contains(w.Events, event)
+}
+
+type DeliveryStatus int
+
+const (
+	DeliveryPending DeliveryStatus = iota
+	DeliverySucceeded
+	DeliveryFailed
+)
+
+func (d DeliveryStatus) String() string {
+	switch d {
+	case DeliveryPending:
+		return "pending"
+	case DeliverySucceeded:
+		return "succeeded"
+	case DeliveryFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+type WebhookDelivery struct {
+	Id           int
+	WebhookId    int
+	Event        WebhookEvent
+	Payload      string
+	Status       DeliveryStatus
+	Attempts     int
+	NextAttempt  time.Time
+	ResponseCode int
+	Error        string
+	Created      time.Time
+
+	// set when fetched for delivery or display
+	Url    string
+	Secret string
+}
+
+func joinEvents(events []WebhookEvent) string {
+	s := make([]string, len(events))
+	for i, e := range events {
+		s[i] = string(e)
+	}
+	return strings.Join(s, ",")
+}
+
+func splitEvents(s string) []WebhookEvent {
+	var events []WebhookEvent
+	for _, e := range strings.Split(s, ",") {
+		if e != "" {
+			events = append(events, WebhookEvent(e))
+		}
+	}
+	return events
+}
+
+func AddWebhook(e Execer, webhook *Webhook) error {
+	_, err := e.Exec(
+		`insert into webhooks (repo_at, url, secret, events) values (?, ?, ?, ?)`,
+		webhook.RepoAt, webhook.Url, webhook.Secret, joinEvents(webhook.Events),
+	)
+	return err
+}
+
+func DeleteWebhook(e Execer, repoAt syntax.ATURI, id int) error {
+	_, err := e.Exec(`delete from webhooks where repo_at = ? and id = ?`, repoAt, id)
+	return err
+}
+
+func GetWebhooks(e Execer, repoAt syntax.ATURI) ([]Webhook, error) {
+	var webhooks []Webhook
+
+	rows, err := e.Query(
+		`select id, repo_at, url, secret, events, active, created from webhooks where repo_at = ? order by id`,
+		repoAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w Webhook
+		var events, createdAt string
+		if err := rows.Scan(&w.Id, &w.RepoAt, &w.Url, &w.Secret, &events, &w.Active, &createdAt); err != nil {
+			return nil, err
+		}
+		w.Events = splitEvents(events)
+		w.Created, _ = time.Parse(time.RFC3339, createdAt)
+		webhooks = append(webhooks, w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// EnqueueWebhookDeliveries queues payload for every active webhook on the
+// repo that subscribes to event.
+func EnqueueWebhookDeliveries(e Execer, repoAt syntax.ATURI, event WebhookEvent, payload []byte) error {
+	webhooks, err := GetWebhooks(e, repoAt)
+	if err != nil {
+		return err
+	}
+
+	for _, w := range webhooks {
+		if !w.Active || !w.Subscribes(event) {
+			continue
+		}
+		_, err := e.Exec(
+			`insert into webhook_deliveries (webhook_id, event, payload) values (?, ?, ?)`,
+			w.Id, event, string(payload),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetDueWebhookDeliveries returns pending deliveries whose next attempt is
+// due, oldest first.
+func GetDueWebhookDeliveries(e Execer, limit int) ([]WebhookDelivery, error) {
+	rows, err := e.Query(
+		`select d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt, d.response_code, d.error, d.created, w.url, w.secret
+		from webhook_deliveries d
+		join webhooks w on w.id = d.webhook_id
+		where d.status = ? and d.next_attempt <= ?
+		order by d.next_attempt
+		limit ?`,
+		DeliveryPending, time.Now().UTC().Format(time.RFC3339), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWebhookDeliveries(rows)
+}
+
+func GetRecentWebhookDeliveries(e Execer, repoAt syntax.ATURI, limit int) ([]WebhookDelivery, error) {
+	rows, err := e.Query(
+		`select d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt, d.response_code, d.error, d.created, w.url, w.secret
+		from webhook_deliveries d
+		join webhooks w on w.id = d.webhook_id
+		where w.repo_at = ?
+		order by d.id desc
+		limit ?`,
+		repoAt, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWebhookDeliveries(rows)
+}
+
+func UpdateWebhookDelivery(e Execer, d *WebhookDelivery) error {
+	_, err := e.Exec(
+		`update webhook_deliveries
+		set status = ?, attempts = ?, next_attempt = ?, response_code = ?, error = ?
+		where id = ?`,
+		d.Status, d.Attempts, d.NextAttempt.UTC().Format(time.RFC3339), d.ResponseCode, d.Error, d.Id,
+	)
+	return err
+}
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+
+	for rows.Next() {
+		var d WebhookDelivery
+		var nextAttempt, createdAt string
+		err := rows.Scan(
+			&d.Id, &d.WebhookId, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+			&nextAttempt, &d.ResponseCode, &d.Error, &createdAt, &d.Url, &d.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+		d.NextAttempt, _ = time.Parse(time.RFC3339, nextAttempt)
+		d.Created, _ = time.Parse(time.RFC3339, createdAt)
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
@@ -120,6 +120,7 @@ func (p *Pages) Timeline(w io.Writer, params TimelineParams) error {
 type SettingsParams struct {
 	LoggedInUser *auth.User
 	PubKeys      []db.PublicKey
+	AppPasswords []db.AppPassword
 }
 
 func (p *Pages) Settings(w io.Writer, params SettingsParams) error {
@@ -155,6 +156,52 @@ func (p *Pages) NewRepo(w io.Writer, params NewRepoParams) error {
 	return p.execute("repo/new", w, params)
 }
 
+type ImportRepoParams struct {
+	LoggedInUser *auth.User
+	Knots        []string
+}
+
+func (p *Pages) ImportRepo(w io.Writer, params ImportRepoParams) error {
+	return p.execute("repo/import", w, params)
+}
+
+type RepoImportStatusParams struct {
+	LoggedInUser *auth.User
+	RepoInfo     RepoInfo
+	Active       string
+	Import       types.ImportResponse
+}
+
+func (p *Pages) RepoImportStatus(w io.Writer, params RepoImportStatusParams) error {
+	return p.executeRepo("repo/importStatus", w, params)
+}
+
+func (p *Pages) ImportStatusFragment(w io.Writer, params RepoImportStatusParams) error {
+	return p.executePlain("fragments/importStatus", w, params)
+}
+
+type ForkRepoParams struct {
+	LoggedInUser *auth.User
+	RepoInfo     RepoInfo
+	Active       string
+	Knots        []string
+}
+
+func (p *Pages) ForkRepo(w io.Writer, params ForkRepoParams) error {
+	return p.executeRepo("repo/fork", w, params)
+}
+
+type RepoTransferParams struct {
+	LoggedInUser *auth.User
+	RepoInfo     RepoInfo
+	Active       string
+	Transfer     db.RepoTransfer
+}
+
+func (p *Pages) RepoTransfer(w io.Writer, params RepoTransferParams) error {
+	return p.executeRepo("repo/transfer", w, params)
+}
+
 type ProfilePageParams struct {
 	LoggedInUser       *auth.User
 	UserDid            string
@@ -215,6 +262,8 @@ type RepoInfo struct {
 	Knot        string
 	RepoAt      syntax.ATURI
 	IsStarred   bool
+	IsPrivate   bool
+	Source      string // full name of the repo this was forked from
 	Stats       db.RepoStats
 	Roles       RolesInRepo
 }
@@ -231,6 +280,22 @@ func (r RolesInRepo) IsOwner() bool {
 	return slices.Contains(r.Roles, "repo:owner")
 }
 
+func (r RolesInRepo) IsPushAllowed() bool {
+	return slices.Contains(r.Roles, "repo:push")
+}
+
+func (r RolesInRepo) IsDeleteAllowed() bool {
+	return slices.Contains(r.Roles, "repo:delete")
+}
+
+func (r RolesInRepo) IsTriageAllowed() bool {
+	return slices.Contains(r.Roles, "repo:triage")
+}
+
+func (r RolesInRepo) IsCollaborator() bool {
+	return slices.Contains(r.Roles, "repo:collaborator")
+}
+
 func (r RepoInfo) OwnerWithAt() string {
 	if r.OwnerHandle != "" {
 		return fmt.Sprintf("@%s", r.OwnerHandle)
@@ -268,6 +333,7 @@ func (r RepoInfo) TabMetadata() map[string]any {
 	meta := make(map[string]any)
 
 	meta["issues"] = r.Stats.IssueCount.Open
+	meta["pulls"] = r.Stats.PullCount.Open
 
 	// more stuff?
 
@@ -320,6 +386,11 @@ func (p *Pages) RepoLog(w io.Writer, params RepoLogParams) error {
 	return p.execute("repo/log", w, params)
 }
 
+func (p *Pages) RepoHistory(w io.Writer, params RepoLogParams) error {
+	params.Active = "overview"
+	return p.executeRepo("repo/history", w, params)
+}
+
 type RepoCommitParams struct {
 	LoggedInUser *auth.User
 	RepoInfo     RepoInfo
@@ -368,6 +439,22 @@ func (p *Pages) RepoTree(w io.Writer, params RepoTreeParams) error {
 	return p.execute("repo/tree", w, params)
 }
 
+type RepoCompareParams struct {
+	LoggedInUser *auth.User
+	RepoInfo     RepoInfo
+	Active       string
+	Branches     []types.Branch
+	Base         string
+	Head         string
+	Error        string
+	Comparison   *types.RepoCompareResponse
+}
+
+func (p *Pages) RepoCompare(w io.Writer, params RepoCompareParams) error {
+	params.Active = "overview"
+	return p.executeRepo("repo/compare", w, params)
+}
+
 type RepoBranchesParams struct {
 	LoggedInUser *auth.User
 	RepoInfo     RepoInfo
@@ -388,14 +475,30 @@ func (p *Pages) RepoTags(w io.Writer, params RepoTagsParams) error {
 	return p.executeRepo("repo/tags", w, params)
 }
 
+type RepoBlameParams struct {
+	LoggedInUser *auth.User
+	RepoInfo     RepoInfo
+	Active       string
+	BreadCrumbs  [][]string
+	types.RepoBlameResponse
+}
+
+func (p *Pages) RepoBlame(w io.Writer, params RepoBlameParams) error {
+	params.Active = "overview"
+	return p.executeRepo("repo/blame", w, params)
+}
+
 type RepoBlobParams struct {
 	LoggedInUser *auth.User
 	RepoInfo     RepoInfo
 	Active       string
 	BreadCrumbs  [][]string
+	IsImage      bool
 	types.RepoBlobResponse
 }
 
+var imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg", ".ico", ".bmp", ".avif"}
+
 func (p *Pages) RepoBlob(w io.Writer, params RepoBlobParams) error {
 	style := styles.Get("bw")
 	b := style.Builder()
@@ -430,22 +533,33 @@ func (p *Pages) RepoBlob(w io.Writer, params RepoBlobParams) error {
 		params.Contents = code.String()
 	}
 
+	params.IsImage = slices.Contains(imageExtensions, strings.ToLower(filepath.Ext(params.Path)))
 	params.Active = "overview"
 	return p.executeRepo("repo/blob", w, params)
 }
 
 type Collaborator struct {
-	Did    string
-	Handle string
-	Role   string
+	Did        string
+	Handle     string
+	Role       string
+	Manageable bool // whether the viewer may change their role or remove them
 }
 
 type RepoSettingsParams struct {
 	LoggedInUser                *auth.User
 	RepoInfo                    RepoInfo
 	Collaborators               []Collaborator
+	CollaboratorRoles           []string
 	Active                      string
 	IsCollaboratorInviteAllowed bool
+	Webhooks                    []db.Webhook
+	WebhookDeliveries           []db.WebhookDelivery
+	WebhookEvents               []db.WebhookEvent
+	BranchRules                 []types.BranchRule
+	Mirror                      *types.Mirror
+	PushMirrors                 []types.PushMirror
+	Transfer                    *db.RepoTransfer
+	Knots                       []string
 }
 
 func (p *Pages) RepoSettings(w io.Writer, params RepoSettingsParams) error {
@@ -505,6 +619,10 @@ type RepoPullsParams struct {
 	LoggedInUser *auth.User
 	RepoInfo     RepoInfo
 	Active       string
+	Pulls        []db.Pull
+	DidHandleMap map[string]string
+
+	FilteringBy db.PullState
 }
 
 func (p *Pages) RepoPulls(w io.Writer, params RepoPullsParams) error {
@@ -512,6 +630,38 @@ func (p *Pages) RepoPulls(w io.Writer, params RepoPullsParams) error {
 	return p.executeRepo("repo/pulls/pulls", w, params)
 }
 
+type RepoNewPullParams struct {
+	LoggedInUser *auth.User
+	RepoInfo     RepoInfo
+	Branches     []types.Branch
+	Active       string
+}
+
+func (p *Pages) RepoNewPull(w io.Writer, params RepoNewPullParams) error {
+	params.Active = "pulls"
+	return p.executeRepo("repo/pulls/new", w, params)
+}
+
+type RepoSinglePullParams struct {
+	LoggedInUser    *auth.User
+	RepoInfo        RepoInfo
+	Active          string
+	Pull            db.Pull
+	Comments        []db.PullComment
+	PullOwnerHandle string
+	DidHandleMap    map[string]string
+	Diff            *types.NiceDiff
+	MergeCheck      *types.MergeCheckResponse
+
+	State string
+}
+
+func (p *Pages) RepoSinglePull(w io.Writer, params RepoSinglePullParams) error {
+	params.Active = "pulls"
+	params.State = params.Pull.State.String()
+	return p.execute("repo/pulls/pull", w, params)
+}
+
 func (p *Pages) Static() http.Handler {
 	sub, err := fs.Sub(files, "static")
 	if err != nil {
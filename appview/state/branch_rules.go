@@ -0,0 +1,116 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sotangled/tangled/appview/db"
+	"github.com/sotangled/tangled/types"
+)
+
+func (s *State) branchRules(f *FullyResolvedRepo) ([]types.BranchRule, error) {
+	resp, err := s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/branch-rules", f.Knot, f.OwnerDid(), f.RepoName))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("knot returned %s", resp.Status)
+	}
+
+	var result types.BranchRulesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Rules, nil
+}
+
+func (s *State) AddBranchRule(w http.ResponseWriter, r *http.Request) {
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	rule := types.BranchRule{
+		Pattern:     strings.TrimSpace(r.FormValue("pattern")),
+		NoForcePush: r.FormValue("no_force_push") == "on",
+		NoDeletion:  r.FormValue("no_deletion") == "on",
+		OwnersOnly:  r.FormValue("owners_only") == "on",
+	}
+	if rule.Pattern == "" {
+		s.pages.Notice(w, "branch-rules", "Branch pattern is required.")
+		return
+	}
+	if !rule.NoForcePush && !rule.NoDeletion && !rule.OwnersOnly {
+		s.pages.Notice(w, "branch-rules", "Select at least one restriction.")
+		return
+	}
+
+	secret, err := db.GetRegistrationKey(s.db, f.Knot)
+	if err != nil {
+		log.Printf("no key found for domain %s: %s\n", f.Knot, err)
+		s.pages.Notice(w, "branch-rules", "Failed to add branch rule.")
+		return
+	}
+
+	ksClient, err := NewSignedClient(f.Knot, secret, s.config.Dev)
+	if err != nil {
+		log.Println("failed to create client to ", f.Knot)
+		s.pages.Notice(w, "branch-rules", "Failed to add branch rule.")
+		return
+	}
+
+	resp, err := ksClient.AddBranchRule(f.OwnerDid(), f.RepoName, rule)
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		log.Println("failed to add branch rule", err)
+		s.pages.Notice(w, "branch-rules", "Failed to add branch rule. Is the pattern a valid glob?")
+		return
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s/settings", f.OwnerSlashRepo()))
+}
+
+func (s *State) RemoveBranchRule(w http.ResponseWriter, r *http.Request) {
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "bad rule id", http.StatusBadRequest)
+		return
+	}
+
+	secret, err := db.GetRegistrationKey(s.db, f.Knot)
+	if err != nil {
+		log.Printf("no key found for domain %s: %s\n", f.Knot, err)
+		s.pages.Notice(w, "branch-rules", "Failed to remove branch rule.")
+		return
+	}
+
+	ksClient, err := NewSignedClient(f.Knot, secret, s.config.Dev)
+	if err != nil {
+		log.Println("failed to create client to ", f.Knot)
+		s.pages.Notice(w, "branch-rules", "Failed to remove branch rule.")
+		return
+	}
+
+	resp, err := ksClient.RemoveBranchRule(f.OwnerDid(), f.RepoName, id)
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		log.Println("failed to remove branch rule", err)
+		s.pages.Notice(w, "branch-rules", "Failed to remove branch rule.")
+		return
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s/settings", f.OwnerSlashRepo()))
+}
@@ -0,0 +1,220 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	lexutil "github.com/bluesky-social/indigo/lex/util"
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/sotangled/tangled/api/tangled"
+	"github.com/sotangled/tangled/appview/db"
+	"github.com/sotangled/tangled/appview/pages"
+)
+
+func (s *State) ForkRepo(w http.ResponseWriter, r *http.Request) {
+	user := s.auth.GetUser(r)
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		knots, err := s.enforcer.GetDomainsForUser(user.Did)
+		if err != nil {
+			s.pages.Notice(w, "repo", "Invalid user account.")
+			return
+		}
+
+		s.pages.ForkRepo(w, pages.ForkRepoParams{
+			LoggedInUser: user,
+			RepoInfo:     f.RepoInfo(s, user),
+			Knots:        knots,
+		})
+
+	case http.MethodPost:
+		domain := r.FormValue("domain")
+		if domain == "" {
+			s.pages.Notice(w, "repo", "Invalid form submission&mdash;missing knot domain.")
+			return
+		}
+
+		repoName := r.FormValue("name")
+		if repoName == "" {
+			repoName = f.RepoName
+		}
+
+		ok, err := s.enforcer.E.Enforce(user.Did, domain, domain, "repo:create")
+		if err != nil || !ok {
+			s.pages.Notice(w, "repo", "You do not have permission to create a repo in this knot.")
+			return
+		}
+
+		// the knot clones the source over plain http, without any credentials
+		public, err := s.enforcer.IsReadAllowed("", f.Knot, f.OwnerSlashRepo())
+		if err != nil || !public {
+			s.pages.Notice(w, "repo", "Private repositories cannot be forked.")
+			return
+		}
+
+		existingRepo, err := db.GetRepo(s.db, user.Did, repoName)
+		if err == nil && existingRepo != nil {
+			s.pages.Notice(w, "repo", fmt.Sprintf("A repo by this name already exists on %s", template.HTMLEscapeString(existingRepo.Knot)))
+			return
+		}
+
+		secret, err := db.GetRegistrationKey(s.db, domain)
+		if err != nil {
+			s.pages.Notice(w, "repo", fmt.Sprintf("No registration key found for knot %s.", template.HTMLEscapeString(domain)))
+			return
+		}
+
+		client, err := NewSignedClient(domain, secret, s.config.Dev)
+		if err != nil {
+			s.pages.Notice(w, "repo", "Failed to connect to knot server.")
+			return
+		}
+
+		scheme := "https"
+		if s.config.Dev {
+			scheme = "http"
+		}
+		sourceUrl := fmt.Sprintf("%s://%s/%s/%s", scheme, f.Knot, f.OwnerDid(), f.RepoName)
+		sourceAt := f.RepoAt.String()
+
+		rkey := s.TID()
+		repo := &db.Repo{
+			Did:         user.Did,
+			Name:        repoName,
+			Knot:        domain,
+			Rkey:        rkey,
+			Description: f.Description,
+			Source:      sourceAt,
+		}
+
+		xrpcClient, _ := s.auth.AuthorizedClient(r)
+
+		addedAt := time.Now().Format(time.RFC3339)
+		atresp, err := comatproto.RepoPutRecord(r.Context(), xrpcClient, &comatproto.RepoPutRecord_Input{
+			Collection: tangled.RepoNSID,
+			Repo:       user.Did,
+			Rkey:       rkey,
+			Record: &lexutil.LexiconTypeDecoder{
+				Val: &tangled.Repo{
+					Knot:    repo.Knot,
+					Name:    repoName,
+					AddedAt: &addedAt,
+					Owner:   user.Did,
+					Source:  &sourceAt,
+				}},
+		})
+		if err != nil {
+			log.Printf("failed to create record: %s", err)
+			s.pages.Notice(w, "repo", "Failed to announce repository creation.")
+			return
+		}
+		log.Println("created repo record: ", atresp.Uri)
+
+		tx, err := s.db.BeginTx(r.Context(), nil)
+		if err != nil {
+			log.Println(err)
+			s.pages.Notice(w, "repo", "Failed to save repository information.")
+			return
+		}
+		defer func() {
+			tx.Rollback()
+			err = s.enforcer.E.LoadPolicy()
+			if err != nil {
+				log.Println("failed to rollback policies")
+			}
+		}()
+
+		resp, err := client.ForkRepo(user.Did, repoName, sourceUrl)
+		if err != nil {
+			s.pages.Notice(w, "repo", "Failed to create repository on knot server.")
+			return
+		}
+		defer resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusAccepted:
+			// continue
+		case http.StatusConflict:
+			s.pages.Notice(w, "repo", "A repository with that name already exists.")
+			return
+		case http.StatusBadRequest:
+			var knotErr struct {
+				Error string `json:"error"`
+			}
+			json.NewDecoder(resp.Body).Decode(&knotErr)
+			s.pages.Notice(w, "repo", fmt.Sprintf("The knot refused to fork this repository: %s", template.HTMLEscapeString(knotErr.Error)))
+			return
+		default:
+			log.Printf("failed to fork %s on %s: %s", f.OwnerSlashRepo(), domain, resp.Status)
+			s.pages.Notice(w, "repo", "Failed to fork repository on knot. Try again later.")
+			return
+		}
+
+		repo.AtUri = atresp.Uri
+		err = db.AddRepo(tx, repo)
+		if err != nil {
+			log.Println(err)
+			s.pages.Notice(w, "repo", "Failed to save repository information.")
+			return
+		}
+
+		p, _ := securejoin.SecureJoin(user.Did, repoName)
+		err = s.enforcer.AddRepo(user.Did, domain, p)
+		if err == nil {
+			err = s.enforcer.MakeRepoPublic(domain, p)
+		}
+		if err != nil {
+			log.Println(err)
+			s.pages.Notice(w, "repo", "Failed to set up repository permissions.")
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Println("failed to commit changes", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		err = s.enforcer.E.SavePolicy()
+		if err != nil {
+			log.Println("failed to update ACLs", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// the knot clones the source in the background
+		s.pages.HxLocation(w, fmt.Sprintf("/@%s/%s/import", user.Handle, repoName))
+		return
+	}
+}
+
+// repoFullName returns the owner/name of the repo at repoAt, preferring the
+// owner's handle to their DID.
+func (s *State) repoFullName(repoAt string) string {
+	repo, err := db.GetRepoByAtUri(s.db, repoAt)
+	if err != nil {
+		log.Println("failed to get source repo", err)
+		return ""
+	}
+
+	owner := repo.Did
+	if id, err := s.resolver.ResolveIdent(context.Background(), repo.Did); err == nil && !id.Handle.IsInvalidHandle() {
+		owner = "@" + id.Handle.String()
+	}
+
+	return path.Join(owner, repo.Name)
+}
@@ -19,7 +19,24 @@ func (s *State) InfoRefs(w http.ResponseWriter, r *http.Request) {
 		scheme = "http"
 	}
 	targetURL := fmt.Sprintf("%s://%s/%s/%s/info/refs?%s", scheme, knot, user.DID, repo, r.URL.RawQuery)
-	resp, err := http.Get(targetURL)
+
+	proxyReq, err := http.NewRequest(http.MethodGet, targetURL, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// pushes are authenticated by the knot
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		proxyReq.Header.Set("Authorization", auth)
+	}
+
+	// needed for protocol v2 negotiation
+	if protocol := r.Header.Get("Git-Protocol"); protocol != "" {
+		proxyReq.Header.Set("Git-Protocol", protocol)
+	}
+
+	resp, err := http.DefaultClient.Do(proxyReq)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -43,6 +60,14 @@ func (s *State) InfoRefs(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *State) UploadPack(w http.ResponseWriter, r *http.Request) {
+	s.proxyGitService(w, r, "git-upload-pack")
+}
+
+func (s *State) ReceivePack(w http.ResponseWriter, r *http.Request) {
+	s.proxyGitService(w, r, "git-receive-pack")
+}
+
+func (s *State) proxyGitService(w http.ResponseWriter, r *http.Request, service string) {
 	user, ok := r.Context().Value("resolvedId").(identity.Identity)
 	if !ok {
 		http.Error(w, "failed to resolve user", http.StatusInternalServerError)
@@ -55,7 +80,7 @@ func (s *State) UploadPack(w http.ResponseWriter, r *http.Request) {
 	if s.config.Dev {
 		scheme = "http"
 	}
-	targetURL := fmt.Sprintf("%s://%s/%s/%s/git-upload-pack?%s", scheme, knot, user.DID, repo, r.URL.RawQuery)
+	targetURL := fmt.Sprintf("%s://%s/%s/%s/%s?%s", scheme, knot, user.DID, repo, service, r.URL.RawQuery)
 	client := &http.Client{}
 
 	// Create new request
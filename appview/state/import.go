@@ -0,0 +1,255 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	lexutil "github.com/bluesky-social/indigo/lex/util"
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/sotangled/tangled/api/tangled"
+	"github.com/sotangled/tangled/appview/db"
+	"github.com/sotangled/tangled/appview/pages"
+	"github.com/sotangled/tangled/types"
+)
+
+func (s *State) ImportRepo(w http.ResponseWriter, r *http.Request) {
+	user := s.auth.GetUser(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		knots, err := s.enforcer.GetDomainsForUser(user.Did)
+		if err != nil {
+			s.pages.Notice(w, "repo", "Invalid user account.")
+			return
+		}
+
+		s.pages.ImportRepo(w, pages.ImportRepoParams{
+			LoggedInUser: user,
+			Knots:        knots,
+		})
+
+	case http.MethodPost:
+		domain := r.FormValue("domain")
+		if domain == "" {
+			s.pages.Notice(w, "repo", "Invalid form submission&mdash;missing knot domain.")
+			return
+		}
+
+		source := strings.TrimSpace(r.FormValue("source"))
+		if source == "" {
+			s.pages.Notice(w, "repo", "Invalid clone URL.")
+			return
+		}
+
+		repoName := r.FormValue("name")
+		if repoName == "" {
+			repoName = repoNameFromUrl(source)
+		}
+		if repoName == "" {
+			s.pages.Notice(w, "repo", "Invalid repo name.")
+			return
+		}
+
+		description := r.FormValue("description")
+		private := r.FormValue("private") == "on"
+		mirror := r.FormValue("mirror") == "on"
+
+		ok, err := s.enforcer.E.Enforce(user.Did, domain, domain, "repo:create")
+		if err != nil || !ok {
+			s.pages.Notice(w, "repo", "You do not have permission to create a repo in this knot.")
+			return
+		}
+
+		existingRepo, err := db.GetRepo(s.db, user.Did, repoName)
+		if err == nil && existingRepo != nil {
+			s.pages.Notice(w, "repo", fmt.Sprintf("A repo by this name already exists on %s", template.HTMLEscapeString(existingRepo.Knot)))
+			return
+		}
+
+		secret, err := db.GetRegistrationKey(s.db, domain)
+		if err != nil {
+			s.pages.Notice(w, "repo", fmt.Sprintf("No registration key found for knot %s.", template.HTMLEscapeString(domain)))
+			return
+		}
+
+		client, err := NewSignedClient(domain, secret, s.config.Dev)
+		if err != nil {
+			s.pages.Notice(w, "repo", "Failed to connect to knot server.")
+			return
+		}
+
+		rkey := s.TID()
+		repo := &db.Repo{
+			Did:         user.Did,
+			Name:        repoName,
+			Knot:        domain,
+			Rkey:        rkey,
+			Description: description,
+		}
+
+		xrpcClient, _ := s.auth.AuthorizedClient(r)
+
+		addedAt := time.Now().Format(time.RFC3339)
+		atresp, err := comatproto.RepoPutRecord(r.Context(), xrpcClient, &comatproto.RepoPutRecord_Input{
+			Collection: tangled.RepoNSID,
+			Repo:       user.Did,
+			Rkey:       rkey,
+			Record: &lexutil.LexiconTypeDecoder{
+				Val: &tangled.Repo{
+					Knot:    repo.Knot,
+					Name:    repoName,
+					AddedAt: &addedAt,
+					Owner:   user.Did,
+				}},
+		})
+		if err != nil {
+			log.Printf("failed to create record: %s", err)
+			s.pages.Notice(w, "repo", "Failed to announce repository creation.")
+			return
+		}
+		log.Println("created repo record: ", atresp.Uri)
+
+		tx, err := s.db.BeginTx(r.Context(), nil)
+		if err != nil {
+			log.Println(err)
+			s.pages.Notice(w, "repo", "Failed to save repository information.")
+			return
+		}
+		defer func() {
+			tx.Rollback()
+			err = s.enforcer.E.LoadPolicy()
+			if err != nil {
+				log.Println("failed to rollback policies")
+			}
+		}()
+
+		resp, err := client.ImportRepo(user.Did, repoName, source, private, mirror)
+		if err != nil {
+			s.pages.Notice(w, "repo", "Failed to create repository on knot server.")
+			return
+		}
+		defer resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusAccepted:
+			// continue
+		case http.StatusConflict:
+			s.pages.Notice(w, "repo", "A repository with that name already exists.")
+			return
+		case http.StatusBadRequest:
+			var knotErr struct {
+				Error string `json:"error"`
+			}
+			json.NewDecoder(resp.Body).Decode(&knotErr)
+			s.pages.Notice(w, "repo", fmt.Sprintf("The knot refused to import this repository: %s", template.HTMLEscapeString(knotErr.Error)))
+			return
+		default:
+			log.Printf("failed to import %s on %s: %s", source, domain, resp.Status)
+			s.pages.Notice(w, "repo", "Failed to import repository on knot. Try again later.")
+			return
+		}
+
+		repo.AtUri = atresp.Uri
+		err = db.AddRepo(tx, repo)
+		if err != nil {
+			log.Println(err)
+			s.pages.Notice(w, "repo", "Failed to save repository information.")
+			return
+		}
+
+		p, _ := securejoin.SecureJoin(user.Did, repoName)
+		err = s.enforcer.AddRepo(user.Did, domain, p)
+		if err == nil && !private {
+			err = s.enforcer.MakeRepoPublic(domain, p)
+		}
+		if err != nil {
+			log.Println(err)
+			s.pages.Notice(w, "repo", "Failed to set up repository permissions.")
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Println("failed to commit changes", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		err = s.enforcer.E.SavePolicy()
+		if err != nil {
+			log.Println("failed to update ACLs", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		s.pages.HxLocation(w, fmt.Sprintf("/@%s/%s/import", user.Handle, repoName))
+		return
+	}
+}
+
+// RepoImportStatus shows the progress of an import. htmx requests only get
+// the status fragment, which polls this handler until the import is done.
+func (s *State) RepoImportStatus(w http.ResponseWriter, r *http.Request) {
+	user := s.auth.GetUser(r)
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	resp, err := s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/import", f.Knot, f.OwnerDid(), f.RepoName))
+	if err != nil {
+		log.Println("failed to reach knotserver", err)
+		s.pages.Error503(w)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		s.pages.Error404(w)
+		return
+	}
+
+	var result types.ImportResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Println("failed to parse import status", err)
+		s.pages.Error503(w)
+		return
+	}
+
+	params := pages.RepoImportStatusParams{
+		LoggedInUser: user,
+		RepoInfo:     f.RepoInfo(s, user),
+		Import:       result,
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		s.pages.ImportStatusFragment(w, params)
+		return
+	}
+
+	s.pages.RepoImportStatus(w, params)
+}
+
+// repoNameFromUrl guesses a repo name from a clone url, e.g. "bar" from
+// "https://example.com/foo/bar.git".
+func repoNameFromUrl(source string) string {
+	name := path.Base(strings.TrimRight(source, "/"))
+	name = strings.TrimSuffix(name, ".git")
+	// scp-like urls, such as git@example.com:bar
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
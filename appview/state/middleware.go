@@ -148,6 +148,62 @@ func RepoPermissionMiddleware(s *State, requiredPerm string) Middleware {
 	}
 }
 
+// RepoReadMiddleware hides private repos from anyone without repo:read.
+func RepoReadMiddleware(s *State) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			f, err := fullyResolvedRepo(r)
+			if err != nil {
+				http.Error(w, "malformed url", http.StatusBadRequest)
+				return
+			}
+
+			var did string
+			if actor := s.auth.GetUser(r); actor != nil {
+				did = actor.Did
+			}
+
+			ok, err := s.enforcer.IsReadAllowed(did, f.Knot, f.OwnerSlashRepo())
+			if err != nil || !ok {
+				w.WriteHeader(http.StatusNotFound)
+				s.pages.Error404(w)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// KnotSignatureMiddleware verifies that a request was signed by the knot in
+// the {domain} url param, using its registration secret.
+func KnotSignatureMiddleware(s *State) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			domain := chi.URLParam(r, "domain")
+			if domain == "" {
+				http.Error(w, "malformed url", http.StatusBadRequest)
+				return
+			}
+
+			secret, err := db.GetRegistrationKey(s.db, domain)
+			if err != nil || secret == "" {
+				log.Printf("no registration key found for domain %s: %s", domain, err)
+				http.Error(w, "unknown knot", http.StatusForbidden)
+				return
+			}
+
+			if !verifySignature(secret, r) {
+				log.Printf("signature verification failed for domain %s", domain)
+				http.Error(w, "signature verification failed", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func StripLeadingAt(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		path := req.URL.Path
@@ -191,6 +247,9 @@ func ResolveRepoKnot(s *State) Middleware {
 
 			repo, err := db.GetRepo(s.db, id.DID.String(), repoName)
 			if err != nil {
+				if s.redirectRenamedRepo(w, req, id.DID.String(), repoName) {
+					return
+				}
 				// invalid did or handle
 				log.Println("failed to resolve repo")
 				w.WriteHeader(http.StatusNotFound)
@@ -201,6 +260,7 @@ func ResolveRepoKnot(s *State) Middleware {
 			ctx = context.WithValue(ctx, "repoAt", repo.AtUri)
 			ctx = context.WithValue(ctx, "repoDescription", repo.Description)
 			ctx = context.WithValue(ctx, "repoAddedAt", repo.Created.Format(time.RFC3339))
+			ctx = context.WithValue(ctx, "repoSource", repo.Source)
 			next.ServeHTTP(w, req.WithContext(ctx))
 		})
 	}
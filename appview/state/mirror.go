@@ -0,0 +1,238 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sotangled/tangled/appview/db"
+	"github.com/sotangled/tangled/types"
+)
+
+// mirror returns the upstream f is mirroring, or nil if f isn't a mirror.
+func (s *State) mirror(f *FullyResolvedRepo) (*types.Mirror, error) {
+	resp, err := s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/mirror", f.Knot, f.OwnerDid(), f.RepoName))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("knot returned %s", resp.Status)
+	}
+
+	var result types.Mirror
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s *State) SetMirror(w http.ResponseWriter, r *http.Request) {
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	source := strings.TrimSpace(r.FormValue("source"))
+	if source == "" {
+		s.pages.Notice(w, "mirror", "Upstream clone URL is required.")
+		return
+	}
+
+	interval, err := strconv.Atoi(r.FormValue("interval"))
+	if err != nil || interval <= 0 {
+		s.pages.Notice(w, "mirror", "Sync interval must be a number of minutes.")
+		return
+	}
+
+	secret, err := db.GetRegistrationKey(s.db, f.Knot)
+	if err != nil {
+		log.Printf("no key found for domain %s: %s\n", f.Knot, err)
+		s.pages.Notice(w, "mirror", "Failed to set up mirror.")
+		return
+	}
+
+	ksClient, err := NewSignedClient(f.Knot, secret, s.config.Dev)
+	if err != nil {
+		log.Println("failed to create client to ", f.Knot)
+		s.pages.Notice(w, "mirror", "Failed to set up mirror.")
+		return
+	}
+
+	resp, err := ksClient.SetMirror(f.OwnerDid(), f.RepoName, source, interval)
+	if err != nil {
+		log.Println("failed to set mirror", err)
+		s.pages.Notice(w, "mirror", "Failed to set up mirror.")
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+	case http.StatusBadRequest:
+		var knotErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&knotErr)
+		s.pages.Notice(w, "mirror", fmt.Sprintf("Failed to set up mirror: %s", template.HTMLEscapeString(knotErr.Error)))
+		return
+	default:
+		log.Println("failed to set mirror", resp.Status)
+		s.pages.Notice(w, "mirror", "Failed to set up mirror.")
+		return
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s/settings", f.OwnerSlashRepo()))
+}
+
+func (s *State) RemoveMirror(w http.ResponseWriter, r *http.Request) {
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	secret, err := db.GetRegistrationKey(s.db, f.Knot)
+	if err != nil {
+		log.Printf("no key found for domain %s: %s\n", f.Knot, err)
+		s.pages.Notice(w, "mirror", "Failed to stop mirroring.")
+		return
+	}
+
+	ksClient, err := NewSignedClient(f.Knot, secret, s.config.Dev)
+	if err != nil {
+		log.Println("failed to create client to ", f.Knot)
+		s.pages.Notice(w, "mirror", "Failed to stop mirroring.")
+		return
+	}
+
+	resp, err := ksClient.RemoveMirror(f.OwnerDid(), f.RepoName)
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		log.Println("failed to remove mirror", err)
+		s.pages.Notice(w, "mirror", "Failed to stop mirroring.")
+		return
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s/settings", f.OwnerSlashRepo()))
+}
+
+func (s *State) pushMirrors(f *FullyResolvedRepo) ([]types.PushMirror, error) {
+	resp, err := s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/push-mirrors", f.Knot, f.OwnerDid(), f.RepoName))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("knot returned %s", resp.Status)
+	}
+
+	var result types.PushMirrorsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Mirrors, nil
+}
+
+func (s *State) AddPushMirror(w http.ResponseWriter, r *http.Request) {
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	url := strings.TrimSpace(r.FormValue("url"))
+	if url == "" {
+		s.pages.Notice(w, "push-mirrors", "Remote URL is required.")
+		return
+	}
+
+	secret, err := db.GetRegistrationKey(s.db, f.Knot)
+	if err != nil {
+		log.Printf("no key found for domain %s: %s\n", f.Knot, err)
+		s.pages.Notice(w, "push-mirrors", "Failed to add push mirror.")
+		return
+	}
+
+	ksClient, err := NewSignedClient(f.Knot, secret, s.config.Dev)
+	if err != nil {
+		log.Println("failed to create client to ", f.Knot)
+		s.pages.Notice(w, "push-mirrors", "Failed to add push mirror.")
+		return
+	}
+
+	resp, err := ksClient.AddPushMirror(f.OwnerDid(), f.RepoName, url, r.FormValue("username"), r.FormValue("password"))
+	if err != nil {
+		log.Println("failed to add push mirror", err)
+		s.pages.Notice(w, "push-mirrors", "Failed to add push mirror.")
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+	case http.StatusBadRequest:
+		var knotErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&knotErr)
+		s.pages.Notice(w, "push-mirrors", fmt.Sprintf("Failed to add push mirror: %s", template.HTMLEscapeString(knotErr.Error)))
+		return
+	default:
+		log.Println("failed to add push mirror", resp.Status)
+		s.pages.Notice(w, "push-mirrors", "Failed to add push mirror.")
+		return
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s/settings", f.OwnerSlashRepo()))
+}
+
+func (s *State) RemovePushMirror(w http.ResponseWriter, r *http.Request) {
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "bad push mirror id", http.StatusBadRequest)
+		return
+	}
+
+	secret, err := db.GetRegistrationKey(s.db, f.Knot)
+	if err != nil {
+		log.Printf("no key found for domain %s: %s\n", f.Knot, err)
+		s.pages.Notice(w, "push-mirrors", "Failed to remove push mirror.")
+		return
+	}
+
+	ksClient, err := NewSignedClient(f.Knot, secret, s.config.Dev)
+	if err != nil {
+		log.Println("failed to create client to ", f.Knot)
+		s.pages.Notice(w, "push-mirrors", "Failed to remove push mirror.")
+		return
+	}
+
+	resp, err := ksClient.RemovePushMirror(f.OwnerDid(), f.RepoName, id)
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		log.Println("failed to remove push mirror", err)
+		s.pages.Notice(w, "push-mirrors", "Failed to remove push mirror.")
+		return
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s/settings", f.OwnerSlashRepo()))
+}
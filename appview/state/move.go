@@ -0,0 +1,225 @@
+package state
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	lexutil "github.com/bluesky-social/indigo/lex/util"
+	"github.com/sotangled/tangled/api/tangled"
+	"github.com/sotangled/tangled/appview/db"
+)
+
+// MoveRepo moves a repo to another knot of the owner's. The git data is
+// bundled up on the current knot and streamed to the new one; issues, pulls
+// and stars hang off the repo's at-uri, which stays the same.
+func (s *State) MoveRepo(w http.ResponseWriter, r *http.Request) {
+	user := s.auth.GetUser(r)
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	domain := r.FormValue("domain")
+	if domain == "" || domain == f.Knot {
+		s.pages.Notice(w, "move", "Pick a knot to move this repository to.")
+		return
+	}
+
+	ok, err := s.enforcer.E.Enforce(user.Did, domain, domain, "repo:create")
+	if err != nil || !ok {
+		s.pages.Notice(w, "move", "You do not have permission to create a repo in this knot.")
+		return
+	}
+
+	secret, err := db.GetRegistrationKey(s.db, domain)
+	if err != nil {
+		log.Printf("no key found for domain %s: %s\n", domain, err)
+		s.pages.Notice(w, "move", fmt.Sprintf("No registration key found for knot %s.", template.HTMLEscapeString(domain)))
+		return
+	}
+
+	ksClient, err := NewSignedClient(domain, secret, s.config.Dev)
+	if err != nil {
+		log.Println("failed to create client to ", domain)
+		s.pages.Notice(w, "move", "Failed to connect to knot server.")
+		return
+	}
+
+	isPublic, err := s.enforcer.IsReadAllowed("", f.Knot, f.OwnerSlashRepo())
+	if err != nil {
+		log.Println("failed to check repo visibility", err)
+	}
+	private := !isPublic
+
+	// branch rules live on the knot, so they are copied over once the new
+	// repo exists
+	rules, err := s.branchRules(f)
+	if err != nil {
+		log.Println("failed to get branch rules", err)
+		s.pages.Notice(w, "move", "Failed to fetch branch rules from the current knot.")
+		return
+	}
+
+	// mirror credentials are sealed on the knot and can't be carried over
+	mirror, err := s.mirror(f)
+	if err != nil {
+		log.Println("failed to get mirror", err)
+		s.pages.Notice(w, "move", "Failed to fetch mirrors from the current knot.")
+		return
+	}
+	pushMirrors, err := s.pushMirrors(f)
+	if err != nil {
+		log.Println("failed to get push mirrors", err)
+		s.pages.Notice(w, "move", "Failed to fetch mirrors from the current knot.")
+		return
+	}
+	if mirror != nil || len(pushMirrors) > 0 {
+		s.pages.Notice(w, "move", "Remove this repository's mirrors before moving it, and set them up again on the new knot.")
+		return
+	}
+
+	bundle, err := s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/bundle", f.Knot, f.OwnerDid(), f.RepoName))
+	if err != nil {
+		log.Printf("failed to bundle %s on %s: %s", f.OwnerSlashRepo(), f.Knot, err)
+		s.pages.Notice(w, "move", "Failed to fetch repository from its current knot.")
+		return
+	}
+	defer bundle.Body.Close()
+
+	var resp *http.Response
+	switch bundle.StatusCode {
+	case http.StatusOK:
+		resp, err = ksClient.ImportBundle(f.OwnerDid(), f.RepoName, private, bundle.Body)
+	case http.StatusNoContent:
+		// nothing pushed yet, an empty repo will do
+		resp, err = ksClient.NewRepo(f.OwnerDid(), f.RepoName, "main", private)
+	default:
+		log.Printf("failed to bundle %s on %s: %s", f.OwnerSlashRepo(), f.Knot, bundle.Status)
+		s.pages.Notice(w, "move", "Failed to fetch repository from its current knot.")
+		return
+	}
+	if err != nil {
+		log.Printf("failed to create %s on %s: %s", f.OwnerSlashRepo(), domain, err)
+		s.pages.Notice(w, "move", "Failed to create repository on the new knot.")
+		return
+	}
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+	case http.StatusConflict:
+		s.pages.Notice(w, "move", "A repository with that name already exists on the new knot.")
+		return
+	default:
+		log.Printf("failed to create %s on %s: %s", f.OwnerSlashRepo(), domain, resp.Status)
+		s.pages.Notice(w, "move", "Failed to create repository on the new knot.")
+		return
+	}
+
+	for _, rule := range rules {
+		resp, err := ksClient.AddBranchRule(f.OwnerDid(), f.RepoName, rule)
+		if err != nil || resp.StatusCode != http.StatusNoContent {
+			log.Printf("failed to add branch rule %s on %s: %v", rule.Pattern, domain, err)
+			// don't leave an unprotected copy behind on the new knot
+			resp, err := ksClient.RemoveRepo(f.OwnerDid(), f.RepoName)
+			if err != nil || resp.StatusCode != http.StatusNoContent {
+				log.Printf("failed to remove %s from %s: %v", f.OwnerSlashRepo(), domain, err)
+			}
+			s.pages.Notice(w, "move", "Failed to copy branch rules to the new knot.")
+			return
+		}
+	}
+
+	collaborators, err := f.Collaborators(r.Context(), s)
+	if err != nil {
+		log.Println("failed to get collaborators", err)
+	}
+	for _, c := range collaborators {
+		if c.Role == "owner" || c.Role == "" {
+			continue
+		}
+		resp, err := ksClient.AddCollaborator(f.OwnerDid(), f.RepoName, c.Did)
+		if err == nil && resp.StatusCode == http.StatusNoContent {
+			resp, err = ksClient.SetCollaboratorRole(f.OwnerDid(), f.RepoName, c.Did, c.Role)
+		}
+		if err != nil || resp.StatusCode != http.StatusNoContent {
+			log.Printf("failed to add collaborator %s on %s: %v", c.Did, domain, err)
+		}
+	}
+
+	rkey := f.RepoAt.RecordKey().String()
+	client, _ := s.auth.AuthorizedClient(r)
+	ex, err := comatproto.RepoGetRecord(r.Context(), client, "", tangled.RepoNSID, user.Did, rkey)
+	if err != nil {
+		log.Println("failed to get repo record", err)
+	} else if record, ok := ex.Value.Val.(*tangled.Repo); ok {
+		record.Knot = domain
+		_, err = comatproto.RepoPutRecord(r.Context(), client, &comatproto.RepoPutRecord_Input{
+			Collection: tangled.RepoNSID,
+			Repo:       user.Did,
+			Rkey:       rkey,
+			SwapRecord: ex.Cid,
+			Record:     &lexutil.LexiconTypeDecoder{Val: record},
+		})
+		if err != nil {
+			log.Println("failed to update repo record", err)
+		}
+	}
+
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Println("failed to start tx")
+		s.pages.Notice(w, "move", "Failed to move repository.")
+		return
+	}
+	defer func() {
+		tx.Rollback()
+		err = s.enforcer.E.LoadPolicy()
+		if err != nil {
+			log.Println("failed to rollback policies")
+		}
+	}()
+
+	err = db.UpdateRepoKnot(tx, f.RepoAt.String(), domain)
+	if err != nil {
+		log.Println("failed to update repo knot", err)
+		s.pages.Notice(w, "move", "Failed to move repository.")
+		return
+	}
+
+	err = s.enforcer.MoveRepo(f.Knot, domain, f.OwnerSlashRepo())
+	if err != nil {
+		log.Println("failed to move repo policies", err)
+		s.pages.Notice(w, "move", "Failed to move repository.")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Println("failed to commit changes", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = s.enforcer.E.SavePolicy()
+	if err != nil {
+		log.Println("failed to update ACLs", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// the repo lives on the new knot now, the old copy is only cleanup
+	oldSecret, err := db.GetRegistrationKey(s.db, f.Knot)
+	if err != nil {
+		log.Printf("no key found for domain %s: %s\n", f.Knot, err)
+	} else if oldClient, err := NewSignedClient(f.Knot, oldSecret, s.config.Dev); err == nil {
+		resp, err := oldClient.RemoveRepo(f.OwnerDid(), f.RepoName)
+		if err != nil || resp.StatusCode != http.StatusNoContent {
+			log.Printf("failed to remove %s from %s: %v", f.OwnerSlashRepo(), f.Knot, err)
+		}
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s/settings", f.OwnerSlashRepo()))
+}
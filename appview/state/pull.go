@@ -0,0 +1,628 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	lexutil "github.com/bluesky-social/indigo/lex/util"
+	"github.com/go-chi/chi/v5"
+	"github.com/sotangled/tangled/api/tangled"
+	"github.com/sotangled/tangled/appview/db"
+	"github.com/sotangled/tangled/appview/pages"
+	"github.com/sotangled/tangled/types"
+)
+
+func (s *State) RepoPulls(w http.ResponseWriter, r *http.Request) {
+	user := s.auth.GetUser(r)
+	params := r.URL.Query()
+
+	state := db.PullOpen
+	switch params.Get("state") {
+	case "closed":
+		state = db.PullClosed
+	case "merged":
+		state = db.PullMerged
+	}
+
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	pulls, err := db.GetPulls(s.db, f.RepoAt, state)
+	if err != nil {
+		log.Println("failed to get pulls", err)
+		s.pages.Notice(w, "pulls", "Failed to load pulls. Try again later.")
+		return
+	}
+
+	identsToResolve := make([]string, len(pulls))
+	for i, pull := range pulls {
+		identsToResolve[i] = pull.OwnerDid
+	}
+	resolvedIds := s.resolver.ResolveIdents(r.Context(), identsToResolve)
+	didHandleMap := make(map[string]string)
+	for _, identity := range resolvedIds {
+		if !identity.Handle.IsInvalidHandle() {
+			didHandleMap[identity.DID.String()] = fmt.Sprintf("@%s", identity.Handle.String())
+		} else {
+			didHandleMap[identity.DID.String()] = identity.DID.String()
+		}
+	}
+
+	s.pages.RepoPulls(w, pages.RepoPullsParams{
+		LoggedInUser: user,
+		RepoInfo:     f.RepoInfo(s, user),
+		Pulls:        pulls,
+		DidHandleMap: didHandleMap,
+		FilteringBy:  state,
+	})
+}
+
+func (s *State) NewPull(w http.ResponseWriter, r *http.Request) {
+	user := s.auth.GetUser(r)
+
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		resp, err := s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/branches", f.Knot, f.OwnerDid(), f.RepoName))
+		if err != nil {
+			log.Println("failed to reach knotserver", err)
+			return
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Printf("Error reading response body: %v", err)
+			return
+		}
+
+		var result types.RepoBranchesResponse
+		err = json.Unmarshal(body, &result)
+		if err != nil {
+			log.Println("failed to parse response:", err)
+			return
+		}
+
+		s.pages.RepoNewPull(w, pages.RepoNewPullParams{
+			LoggedInUser: user,
+			RepoInfo:     f.RepoInfo(s, user),
+			Branches:     result.Branches,
+		})
+	case http.MethodPost:
+		title := r.FormValue("title")
+		body := r.FormValue("body")
+		targetBranch := r.FormValue("targetBranch")
+		patch := r.FormValue("patch")
+
+		if title == "" || targetBranch == "" || patch == "" {
+			s.pages.Notice(w, "pull", "Title, target branch and patch are required.")
+			return
+		}
+
+		if _, err := parsePatch(patch); err != nil {
+			log.Println("invalid patch", err)
+			s.pages.Notice(w, "pull", "The patch could not be parsed. Make sure it is in git diff or git format-patch format.")
+			return
+		}
+
+		tx, err := s.db.BeginTx(r.Context(), nil)
+		if err != nil {
+			s.pages.Notice(w, "pull", "Failed to create pull request, try again later")
+			return
+		}
+
+		pull := &db.Pull{
+			RepoAt:       f.RepoAt,
+			OwnerDid:     user.Did,
+			Title:        title,
+			Body:         body,
+			TargetBranch: targetBranch,
+			Patch:        patch,
+		}
+		err = db.NewPull(tx, pull)
+		if err != nil {
+			log.Println("failed to create pull request", err)
+			s.pages.Notice(w, "pull", "Failed to create pull request.")
+			return
+		}
+
+		if !f.isPrivate(s) {
+			client, _ := s.auth.AuthorizedClient(r)
+			createdAt := time.Now().Format(time.RFC3339)
+			atResp, err := comatproto.RepoPutRecord(r.Context(), client, &comatproto.RepoPutRecord_Input{
+				Collection: tangled.RepoPullNSID,
+				Repo:       user.Did,
+				Rkey:       s.TID(),
+				Record: &lexutil.LexiconTypeDecoder{
+					Val: &tangled.RepoPull{
+						TargetRepo:   f.RepoAt.String(),
+						TargetBranch: targetBranch,
+						PullId:       int64(pull.PullId),
+						Title:        title,
+						Body:         &body,
+						Patch:        patch,
+						CreatedAt:    &createdAt,
+					},
+				},
+			})
+			if err != nil {
+				log.Println("failed to create pull request record", err)
+				s.pages.Notice(w, "pull", "Failed to create pull request.")
+				return
+			}
+
+			err = db.SetPullAt(s.db, f.RepoAt, pull.PullId, atResp.Uri)
+			if err != nil {
+				log.Println("failed to set pull at", err)
+				s.pages.Notice(w, "pull", "Failed to create pull request.")
+				return
+			}
+		}
+
+		s.pages.HxLocation(w, fmt.Sprintf("/%s/pulls/%d", f.OwnerSlashRepo(), pull.PullId))
+		return
+	}
+}
+
+func (s *State) RepoSinglePull(w http.ResponseWriter, r *http.Request) {
+	user := s.auth.GetUser(r)
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	pullId := chi.URLParam(r, "pull")
+	pullIdInt, err := strconv.Atoi(pullId)
+	if err != nil {
+		http.Error(w, "bad pull id", http.StatusBadRequest)
+		log.Println("failed to parse pull id", err)
+		return
+	}
+
+	pull, comments, err := db.GetPullWithComments(s.db, f.RepoAt, pullIdInt)
+	if err != nil {
+		log.Println("failed to get pull and comments", err)
+		s.pages.Notice(w, "pull", "Failed to load pull request. Try again later.")
+		return
+	}
+
+	pullOwnerIdent, err := s.resolver.ResolveIdent(r.Context(), pull.OwnerDid)
+	if err != nil {
+		log.Println("failed to resolve pull owner", err)
+	}
+
+	identsToResolve := make([]string, len(comments))
+	for i, comment := range comments {
+		identsToResolve[i] = comment.OwnerDid
+	}
+	resolvedIds := s.resolver.ResolveIdents(r.Context(), identsToResolve)
+	didHandleMap := make(map[string]string)
+	for _, identity := range resolvedIds {
+		if !identity.Handle.IsInvalidHandle() {
+			didHandleMap[identity.DID.String()] = fmt.Sprintf("@%s", identity.Handle.String())
+		} else {
+			didHandleMap[identity.DID.String()] = identity.DID.String()
+		}
+	}
+
+	diff, err := parsePatch(pull.Patch)
+	if err != nil {
+		log.Println("failed to parse patch", err)
+	}
+
+	var mergeCheck *types.MergeCheckResponse
+	if pull.State == db.PullOpen {
+		mergeCheck = s.mergeCheck(f, pull)
+	}
+
+	var pullOwnerHandle string
+	if pullOwnerIdent != nil {
+		pullOwnerHandle = pullOwnerIdent.Handle.String()
+	}
+
+	s.pages.RepoSinglePull(w, pages.RepoSinglePullParams{
+		LoggedInUser:    user,
+		RepoInfo:        f.RepoInfo(s, user),
+		Pull:            *pull,
+		Comments:        comments,
+		PullOwnerHandle: pullOwnerHandle,
+		DidHandleMap:    didHandleMap,
+		Diff:            diff,
+		MergeCheck:      mergeCheck,
+	})
+}
+
+// mergeCheck asks the knot whether the pull's patch still applies to its
+// target branch. A nil result means the knot could not be asked.
+func (s *State) mergeCheck(f *FullyResolvedRepo, pull *db.Pull) *types.MergeCheckResponse {
+	secret, err := db.GetRegistrationKey(s.db, f.Knot)
+	if err != nil {
+		log.Printf("no key found for domain %s: %s\n", f.Knot, err)
+		return nil
+	}
+
+	ksClient, err := NewSignedClient(f.Knot, secret, s.config.Dev)
+	if err != nil {
+		log.Println("failed to create client to ", f.Knot)
+		return nil
+	}
+
+	resp, err := ksClient.MergeCheck([]byte(pull.Patch), f.OwnerDid(), f.RepoName, pull.TargetBranch)
+	if err != nil {
+		log.Println("failed to check mergeability", err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Println("knotserver failed to check mergeability", resp.StatusCode)
+		return nil
+	}
+
+	var mergeCheck types.MergeCheckResponse
+	if err := json.NewDecoder(resp.Body).Decode(&mergeCheck); err != nil {
+		log.Println("failed to parse merge check response", err)
+		return nil
+	}
+
+	return &mergeCheck
+}
+
+func (s *State) PullComment(w http.ResponseWriter, r *http.Request) {
+	user := s.auth.GetUser(r)
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	pullId := chi.URLParam(r, "pull")
+	pullIdInt, err := strconv.Atoi(pullId)
+	if err != nil {
+		http.Error(w, "bad pull id", http.StatusBadRequest)
+		log.Println("failed to parse pull id", err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		body := r.FormValue("body")
+		if body == "" {
+			s.pages.Notice(w, "pull-comment", "Body is required")
+			return
+		}
+
+		commentId := rand.IntN(1000000)
+
+		var commentAt string
+		if !f.isPrivate(s) {
+			pullAt, err := db.GetPullAt(s.db, f.RepoAt, pullIdInt)
+			if err != nil {
+				log.Println("failed to get pull at", err)
+				s.pages.Notice(w, "pull-comment", "Failed to create comment.")
+				return
+			}
+
+			createdAt := time.Now().Format(time.RFC3339)
+			commentIdInt64 := int64(commentId)
+			ownerDid := user.Did
+			atUri := f.RepoAt.String()
+
+			client, _ := s.auth.AuthorizedClient(r)
+			atResp, err := comatproto.RepoPutRecord(r.Context(), client, &comatproto.RepoPutRecord_Input{
+				Collection: tangled.RepoPullCommentNSID,
+				Repo:       user.Did,
+				Rkey:       s.TID(),
+				Record: &lexutil.LexiconTypeDecoder{
+					Val: &tangled.RepoPullComment{
+						Repo:      &atUri,
+						Pull:      pullAt,
+						CommentId: &commentIdInt64,
+						Owner:     &ownerDid,
+						Body:      &body,
+						CreatedAt: &createdAt,
+					},
+				},
+			})
+			if err != nil {
+				log.Println("failed to create comment", err)
+				s.pages.Notice(w, "pull-comment", "Failed to create comment.")
+				return
+			}
+			commentAt = atResp.Uri
+		}
+
+		err = db.NewPullComment(s.db, &db.PullComment{
+			OwnerDid:  user.Did,
+			RepoAt:    f.RepoAt,
+			CommentAt: commentAt,
+			Pull:      pullIdInt,
+			CommentId: commentId,
+			Body:      body,
+		})
+		if err != nil {
+			log.Println("failed to create comment", err)
+			s.pages.Notice(w, "pull-comment", "Failed to create comment.")
+			return
+		}
+
+		s.pages.HxLocation(w, fmt.Sprintf("/%s/pulls/%d#comment-%d", f.OwnerSlashRepo(), pullIdInt, commentId))
+		return
+	}
+}
+
+func (s *State) ClosePull(w http.ResponseWriter, r *http.Request) {
+	s.setPullState(w, r, db.PullClosed)
+}
+
+func (s *State) ReopenPull(w http.ResponseWriter, r *http.Request) {
+	s.setPullState(w, r, db.PullOpen)
+}
+
+func (s *State) setPullState(w http.ResponseWriter, r *http.Request, state db.PullState) {
+	user := s.auth.GetUser(r)
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	pullId := chi.URLParam(r, "pull")
+	pullIdInt, err := strconv.Atoi(pullId)
+	if err != nil {
+		http.Error(w, "bad pull id", http.StatusBadRequest)
+		log.Println("failed to parse pull id", err)
+		return
+	}
+
+	pull, err := db.GetPull(s.db, f.RepoAt, pullIdInt)
+	if err != nil {
+		log.Println("failed to get pull", err)
+		s.pages.Notice(w, "pull-action", "Failed to update pull request. Try again later.")
+		return
+	}
+
+	isTriager, _ := s.enforcer.IsTriageAllowed(user.Did, f.Knot, f.OwnerSlashRepo())
+	isPullOwner := user.Did == pull.OwnerDid
+
+	if !isPullOwner && !isTriager {
+		log.Println("user is not permitted to change pull state")
+		http.Error(w, "forbidden", http.StatusUnauthorized)
+		return
+	}
+
+	if pull.State == db.PullMerged {
+		s.pages.Notice(w, "pull-action", "Merged pull requests cannot be closed or reopened.")
+		return
+	}
+
+	status := tangled.RepoPullStatusOpen
+	if state == db.PullClosed {
+		status = tangled.RepoPullStatusClosed
+	}
+
+	if !f.isPrivate(s) {
+		client, _ := s.auth.AuthorizedClient(r)
+		_, err = comatproto.RepoPutRecord(r.Context(), client, &comatproto.RepoPutRecord_Input{
+			Collection: tangled.RepoPullStatusNSID,
+			Repo:       user.Did,
+			Rkey:       s.TID(),
+			Record: &lexutil.LexiconTypeDecoder{
+				Val: &tangled.RepoPullStatus{
+					Pull:   pull.PullAt,
+					Status: &status,
+				},
+			},
+		})
+		if err != nil {
+			log.Println("failed to update pull status", err)
+			s.pages.Notice(w, "pull-action", "Failed to update pull request. Try again later.")
+			return
+		}
+	}
+
+	err = db.SetPullState(s.db, f.RepoAt, pullIdInt, state)
+	if err != nil {
+		log.Println("failed to update pull state", err)
+		s.pages.Notice(w, "pull-action", "Failed to update pull request. Try again later.")
+		return
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s/pulls/%d", f.OwnerSlashRepo(), pullIdInt))
+}
+
+func (s *State) MergePull(w http.ResponseWriter, r *http.Request) {
+	user := s.auth.GetUser(r)
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	pullId := chi.URLParam(r, "pull")
+	pullIdInt, err := strconv.Atoi(pullId)
+	if err != nil {
+		http.Error(w, "bad pull id", http.StatusBadRequest)
+		log.Println("failed to parse pull id", err)
+		return
+	}
+
+	pull, err := db.GetPull(s.db, f.RepoAt, pullIdInt)
+	if err != nil {
+		log.Println("failed to get pull", err)
+		s.pages.Notice(w, "pull-merge", "Failed to merge pull request. Try again later.")
+		return
+	}
+
+	if pull.State != db.PullOpen {
+		s.pages.Notice(w, "pull-merge", "Only open pull requests can be merged.")
+		return
+	}
+
+	secret, err := db.GetRegistrationKey(s.db, f.Knot)
+	if err != nil {
+		log.Printf("no key found for domain %s: %s\n", f.Knot, err)
+		s.pages.Notice(w, "pull-merge", "Failed to merge pull request. Try again later.")
+		return
+	}
+
+	ksClient, err := NewSignedClient(f.Knot, secret, s.config.Dev)
+	if err != nil {
+		log.Println("failed to create client to ", f.Knot)
+		s.pages.Notice(w, "pull-merge", "Failed to merge pull request. Try again later.")
+		return
+	}
+
+	authorName := pull.OwnerDid
+	if ident, err := s.resolver.ResolveIdent(r.Context(), pull.OwnerDid); err == nil && !ident.Handle.IsInvalidHandle() {
+		authorName = ident.Handle.String()
+	}
+
+	commitMessage := pull.Title
+	if pull.Body != "" {
+		commitMessage = fmt.Sprintf("%s\n\n%s", pull.Title, pull.Body)
+	}
+
+	resp, err := ksClient.Merge(f.OwnerDid(), f.RepoName, MergeRequest{
+		Patch:          []byte(pull.Patch),
+		Branch:         pull.TargetBranch,
+		AuthorName:     authorName,
+		AuthorEmail:    pull.OwnerDid,
+		CommitterName:  user.Handle,
+		CommitterEmail: user.Did,
+		CommitMessage:  commitMessage,
+		Pusher:         user.Did,
+	})
+	if err != nil {
+		log.Println("failed to merge pull", err)
+		s.pages.Notice(w, "pull-merge", "Failed to reach knotserver.")
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusConflict:
+		var conflict types.MergeCheckResponse
+		if err := json.NewDecoder(resp.Body).Decode(&conflict); err != nil {
+			log.Println("failed to parse merge response", err)
+		}
+		var files []string
+		for _, c := range conflict.Conflicts {
+			// filenames come from the submitted patch, and notices are raw html
+			files = append(files, template.HTMLEscapeString(c.Filename))
+		}
+		s.pages.Notice(w, "pull-merge", fmt.Sprintf("Merge conflicts in: %s", strings.Join(files, ", ")))
+		return
+	case http.StatusForbidden:
+		var knotErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&knotErr)
+		s.pages.Notice(w, "pull-merge", fmt.Sprintf("The knot refused this merge: %s", template.HTMLEscapeString(knotErr.Error)))
+		return
+	default:
+		log.Println("knotserver failed to merge pull", resp.StatusCode)
+		s.pages.Notice(w, "pull-merge", "Failed to merge pull request. Try again later.")
+		return
+	}
+
+	if !f.isPrivate(s) {
+		status := tangled.RepoPullStatusMerged
+		client, _ := s.auth.AuthorizedClient(r)
+		_, err = comatproto.RepoPutRecord(r.Context(), client, &comatproto.RepoPutRecord_Input{
+			Collection: tangled.RepoPullStatusNSID,
+			Repo:       user.Did,
+			Rkey:       s.TID(),
+			Record: &lexutil.LexiconTypeDecoder{
+				Val: &tangled.RepoPullStatus{
+					Pull:   pull.PullAt,
+					Status: &status,
+				},
+			},
+		})
+		if err != nil {
+			// the branch has already moved, so carry on and record the merge
+			log.Println("failed to write pull status record", err)
+		}
+	}
+
+	err = db.MergePull(s.db, f.RepoAt, pullIdInt)
+	if err != nil {
+		log.Println("failed to update pull state", err)
+		s.pages.Notice(w, "pull-merge", "Pull request was merged, but its state could not be updated.")
+		return
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s/pulls/%d", f.OwnerSlashRepo(), pullIdInt))
+}
+
+// parsePatch turns a submitted patch into the same structure the knot
+// returns for commits, so both can be rendered alike.
+func parsePatch(patch string) (*types.NiceDiff, error) {
+	files, preamble, err := gitdiff.Parse(strings.NewReader(patch))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files in patch")
+	}
+
+	nd := types.NiceDiff{}
+	if header, err := gitdiff.ParsePatchHeader(preamble); err == nil {
+		nd.Commit.Message = header.Message()
+		if header.Author != nil {
+			nd.Commit.Author.Name = header.Author.Name
+			nd.Commit.Author.Email = header.Author.Email
+		}
+		nd.Commit.Author.When = header.AuthorDate
+	}
+
+	for _, d := range files {
+		ndiff := types.Diff{}
+		ndiff.Name.New = d.NewName
+		ndiff.Name.Old = d.OldName
+		ndiff.IsBinary = d.IsBinary
+		ndiff.IsNew = d.IsNew
+		ndiff.IsDelete = d.IsDelete
+		ndiff.IsCopy = d.IsCopy
+		ndiff.IsRename = d.IsRename
+
+		for _, tf := range d.TextFragments {
+			ndiff.TextFragments = append(ndiff.TextFragments, *tf)
+			for _, l := range tf.Lines {
+				switch l.Op {
+				case gitdiff.OpAdd:
+					nd.Stat.Insertions += 1
+				case gitdiff.OpDelete:
+					nd.Stat.Deletions += 1
+				}
+			}
+		}
+
+		nd.Diff = append(nd.Diff, ndiff)
+	}
+
+	nd.Stat.FilesChanged = len(files)
+
+	return &nd, nil
+}
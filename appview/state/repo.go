@@ -2,7 +2,9 @@ package state
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -22,6 +24,7 @@ import (
 	"github.com/sotangled/tangled/appview/auth"
 	"github.com/sotangled/tangled/appview/db"
 	"github.com/sotangled/tangled/appview/pages"
+	"github.com/sotangled/tangled/rbac"
 	"github.com/sotangled/tangled/types"
 
 	comatproto "github.com/bluesky-social/indigo/api/atproto"
@@ -42,7 +45,7 @@ func (s *State) RepoIndex(w http.ResponseWriter, r *http.Request) {
 		reqUrl = fmt.Sprintf("http://%s/%s/%s", f.Knot, f.OwnerDid(), f.RepoName)
 	}
 
-	resp, err := http.Get(reqUrl)
+	resp, err := s.knotClient(f.Knot).Get(reqUrl)
 	if err != nil {
 		s.pages.Error503(w)
 		log.Println("failed to reach knotserver", err)
@@ -101,7 +104,7 @@ func (s *State) RepoLog(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ref := chi.URLParam(r, "ref")
-	resp, err := http.Get(fmt.Sprintf("http://%s/%s/%s/log/%s?page=%d&per_page=30", f.Knot, f.OwnerDid(), f.RepoName, ref, page))
+	resp, err := s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/log/%s?page=%d&per_page=30", f.Knot, f.OwnerDid(), f.RepoName, ref, page))
 	if err != nil {
 		log.Println("failed to reach knotserver", err)
 		return
@@ -129,6 +132,45 @@ func (s *State) RepoLog(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+func (s *State) RepoHistory(w http.ResponseWriter, r *http.Request) {
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to fully resolve repo", err)
+		return
+	}
+
+	page := 1
+	if r.URL.Query().Get("page") != "" {
+		page, err = strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil {
+			page = 1
+		}
+	}
+
+	ref := chi.URLParam(r, "ref")
+	filePath := chi.URLParam(r, "*")
+	resp, err := s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/history/%s/%s?page=%d&per_page=30", f.Knot, f.OwnerDid(), f.RepoName, ref, filePath, page))
+	if err != nil {
+		log.Println("failed to reach knotserver", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	var history types.RepoLogResponse
+	err = json.NewDecoder(resp.Body).Decode(&history)
+	if err != nil {
+		log.Println("failed to parse json response", err)
+		return
+	}
+
+	user := s.auth.GetUser(r)
+	s.pages.RepoHistory(w, pages.RepoLogParams{
+		LoggedInUser:    user,
+		RepoInfo:        f.RepoInfo(s, user),
+		RepoLogResponse: history,
+	})
+}
+
 func (s *State) RepoDescriptionEdit(w http.ResponseWriter, r *http.Request) {
 	f, err := fullyResolvedRepo(r)
 	if err != nil {
@@ -231,7 +273,7 @@ func (s *State) RepoCommit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ref := chi.URLParam(r, "ref")
-	resp, err := http.Get(fmt.Sprintf("http://%s/%s/%s/commit/%s", f.Knot, f.OwnerDid(), f.RepoName, ref))
+	resp, err := s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/commit/%s", f.Knot, f.OwnerDid(), f.RepoName, ref))
 	if err != nil {
 		log.Println("failed to reach knotserver", err)
 		return
@@ -268,7 +310,7 @@ func (s *State) RepoTree(w http.ResponseWriter, r *http.Request) {
 
 	ref := chi.URLParam(r, "ref")
 	treePath := chi.URLParam(r, "*")
-	resp, err := http.Get(fmt.Sprintf("http://%s/%s/%s/tree/%s/%s", f.Knot, f.OwnerDid(), f.RepoName, ref, treePath))
+	resp, err := s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/tree/%s/%s", f.Knot, f.OwnerDid(), f.RepoName, ref, treePath))
 	if err != nil {
 		log.Println("failed to reach knotserver", err)
 		return
@@ -318,7 +360,7 @@ func (s *State) RepoTags(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := http.Get(fmt.Sprintf("http://%s/%s/%s/tags", f.Knot, f.OwnerDid(), f.RepoName))
+	resp, err := s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/tags", f.Knot, f.OwnerDid(), f.RepoName))
 	if err != nil {
 		log.Println("failed to reach knotserver", err)
 		return
@@ -353,7 +395,7 @@ func (s *State) RepoBranches(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := http.Get(fmt.Sprintf("http://%s/%s/%s/branches", f.Knot, f.OwnerDid(), f.RepoName))
+	resp, err := s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/branches", f.Knot, f.OwnerDid(), f.RepoName))
 	if err != nil {
 		log.Println("failed to reach knotserver", err)
 		return
@@ -381,6 +423,75 @@ func (s *State) RepoBranches(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+func (s *State) RepoCompare(w http.ResponseWriter, r *http.Request) {
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	resp, err := s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/branches", f.Knot, f.OwnerDid(), f.RepoName))
+	if err != nil {
+		log.Println("failed to reach knotserver", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	var branches types.RepoBranchesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&branches); err != nil {
+		log.Println("failed to parse response:", err)
+		return
+	}
+
+	user := s.auth.GetUser(r)
+	params := pages.RepoCompareParams{
+		LoggedInUser: user,
+		RepoInfo:     f.RepoInfo(s, user),
+		Branches:     branches.Branches,
+		Head:         r.URL.Query().Get("head"),
+	}
+
+	refRange := chi.URLParam(r, "*")
+	if refRange == "" {
+		s.pages.RepoCompare(w, params)
+		return
+	}
+
+	base, head, ok := strings.Cut(refRange, "...")
+	if !ok {
+		params.Error = "Expected a range of the form base...head."
+		s.pages.RepoCompare(w, params)
+		return
+	}
+	params.Base, params.Head = base, head
+
+	resp, err = s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/compare/%s", f.Knot, f.OwnerDid(), f.RepoName, refRange))
+	if err != nil {
+		log.Println("failed to reach knotserver", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var knotErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&knotErr)
+		params.Error = fmt.Sprintf("Could not compare %s and %s: %s", base, head, knotErr.Error)
+		s.pages.RepoCompare(w, params)
+		return
+	}
+
+	var result types.RepoCompareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Println("failed to parse response:", err)
+		return
+	}
+	params.Comparison = &result
+
+	s.pages.RepoCompare(w, params)
+}
+
 func (s *State) RepoBlob(w http.ResponseWriter, r *http.Request) {
 	f, err := fullyResolvedRepo(r)
 	if err != nil {
@@ -390,7 +501,7 @@ func (s *State) RepoBlob(w http.ResponseWriter, r *http.Request) {
 
 	ref := chi.URLParam(r, "ref")
 	filePath := chi.URLParam(r, "*")
-	resp, err := http.Get(fmt.Sprintf("http://%s/%s/%s/blob/%s/%s", f.Knot, f.OwnerDid(), f.RepoName, ref, filePath))
+	resp, err := s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/blob/%s/%s", f.Knot, f.OwnerDid(), f.RepoName, ref, filePath))
 	if err != nil {
 		log.Println("failed to reach knotserver", err)
 		return
@@ -427,6 +538,90 @@ func (s *State) RepoBlob(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// RepoRaw proxies raw file contents from the knot, passing conditional and
+// range headers through so caching and partial downloads keep working.
+func (s *State) RepoRaw(w http.ResponseWriter, r *http.Request) {
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	ref := chi.URLParam(r, "ref")
+	filePath := chi.URLParam(r, "*")
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, fmt.Sprintf("http://%s/%s/%s/raw/%s/%s", f.Knot, f.OwnerDid(), f.RepoName, ref, filePath), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, h := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"} {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+
+	resp, err := s.knotClient(f.Knot).Do(req)
+	if err != nil {
+		log.Println("failed to reach knotserver", err)
+		s.pages.Error503(w)
+		return
+	}
+	defer resp.Body.Close()
+
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	io.Copy(w, resp.Body)
+}
+
+func (s *State) RepoBlame(w http.ResponseWriter, r *http.Request) {
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	ref := chi.URLParam(r, "ref")
+	filePath := chi.URLParam(r, "*")
+	resp, err := s.knotClient(f.Knot).Get(fmt.Sprintf("http://%s/%s/%s/blame/%s/%s", f.Knot, f.OwnerDid(), f.RepoName, ref, filePath))
+	if err != nil {
+		log.Println("failed to reach knotserver", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		s.pages.Error404(w)
+		return
+	}
+
+	var result types.RepoBlameResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		log.Println("failed to parse response:", err)
+		return
+	}
+
+	var breadcrumbs [][]string
+	breadcrumbs = append(breadcrumbs, []string{f.RepoName, fmt.Sprintf("/%s/%s/tree/%s", f.OwnerDid(), f.RepoName, ref)})
+	if filePath != "" {
+		for idx, elem := range strings.Split(filePath, "/") {
+			breadcrumbs = append(breadcrumbs, []string{elem, fmt.Sprintf("%s/%s", breadcrumbs[idx][1], elem)})
+		}
+	}
+
+	user := s.auth.GetUser(r)
+	s.pages.RepoBlame(w, pages.RepoBlameParams{
+		LoggedInUser:      user,
+		RepoInfo:          f.RepoInfo(s, user),
+		RepoBlameResponse: result,
+		BreadCrumbs:       breadcrumbs,
+	})
+}
+
 func (s *State) AddCollaborator(w http.ResponseWriter, r *http.Request) {
 	f, err := fullyResolvedRepo(r)
 	if err != nil {
@@ -447,6 +642,28 @@ func (s *State) AddCollaborator(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("adding %s to %s\n", collaboratorIdent.Handle.String(), f.Knot)
 
+	// role changes go through SetCollaboratorRole
+	collaboratorDid := collaboratorIdent.DID.String()
+	if collaboratorDid == f.OwnerDid() {
+		w.Write([]byte("the repo owner can't be added as a collaborator"))
+		return
+	}
+	user := s.auth.GetUser(r)
+	current := s.enforcer.GetCollaboratorRole(collaboratorDid, f.Knot, f.OwnerSlashRepo())
+	if current != "" && !s.enforcer.CanManageRole(user.Did, f.Knot, f.OwnerSlashRepo(), current) {
+		w.Write([]byte("you cannot change a collaborator with a higher role than yours"))
+		return
+	}
+	if current != "" {
+		w.Write([]byte(fmt.Sprint("already a collaborator: ", collaboratorIdent.Handle.String())))
+		return
+	}
+	// new collaborators start out with write access
+	if !s.enforcer.CanManageRole(user.Did, f.Knot, f.OwnerSlashRepo(), rbac.CollaboratorWrite) {
+		w.Write([]byte("you cannot grant a role higher than yours"))
+		return
+	}
+
 	// TODO: create an atproto record for this
 
 	secret, err := db.GetRegistrationKey(s.db, f.Knot)
@@ -516,6 +733,293 @@ func (s *State) AddCollaborator(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func (s *State) RemoveCollaborator(w http.ResponseWriter, r *http.Request) {
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	collaborator := r.FormValue("collaborator")
+	if collaborator == "" {
+		http.Error(w, "malformed form", http.StatusBadRequest)
+		return
+	}
+
+	// the form may carry a handle as well as a did
+	collaboratorIdent, err := s.resolver.ResolveIdent(r.Context(), collaborator)
+	if err != nil {
+		s.pages.Notice(w, "collaborators", "Failed to resolve collaborator.")
+		return
+	}
+	collaborator = collaboratorIdent.DID.String()
+
+	user := s.auth.GetUser(r)
+	current := s.enforcer.GetCollaboratorRole(collaborator, f.Knot, f.OwnerSlashRepo())
+	if current == "" {
+		s.pages.Notice(w, "collaborators", "Not a collaborator on this repository.")
+		return
+	}
+	if !s.enforcer.CanManageRole(user.Did, f.Knot, f.OwnerSlashRepo(), current) {
+		s.pages.Notice(w, "collaborators", "You cannot remove a collaborator with a higher role than yours.")
+		return
+	}
+
+	secret, err := db.GetRegistrationKey(s.db, f.Knot)
+	if err != nil {
+		log.Printf("no key found for domain %s: %s\n", f.Knot, err)
+		s.pages.Notice(w, "collaborators", "Failed to remove collaborator.")
+		return
+	}
+
+	ksClient, err := NewSignedClient(f.Knot, secret, s.config.Dev)
+	if err != nil {
+		log.Println("failed to create client to ", f.Knot)
+		s.pages.Notice(w, "collaborators", "Failed to remove collaborator.")
+		return
+	}
+
+	ksResp, err := ksClient.RemoveCollaborator(f.OwnerDid(), f.RepoName, collaborator)
+	if err != nil || ksResp.StatusCode != http.StatusNoContent {
+		log.Printf("failed to remove collaborator on %s: %v", f.Knot, err)
+		s.pages.Notice(w, "collaborators", "Failed to remove collaborator from knot.")
+		return
+	}
+
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Println("failed to start tx")
+		s.pages.Notice(w, "collaborators", "Failed to remove collaborator.")
+		return
+	}
+	defer func() {
+		tx.Rollback()
+		err = s.enforcer.E.LoadPolicy()
+		if err != nil {
+			log.Println("failed to rollback policies")
+		}
+	}()
+
+	err = s.enforcer.RemoveCollaborator(collaborator, f.Knot, f.OwnerSlashRepo())
+	if err != nil {
+		log.Println("failed to remove collaborator", err)
+		s.pages.Notice(w, "collaborators", "Failed to remove collaborator.")
+		return
+	}
+
+	err = db.RemoveCollaborator(tx, collaborator, f.OwnerDid(), f.RepoName, f.Knot)
+	if err != nil {
+		log.Println("failed to remove collaborator", err)
+		s.pages.Notice(w, "collaborators", "Failed to remove collaborator.")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Println("failed to commit changes", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = s.enforcer.E.SavePolicy()
+	if err != nil {
+		log.Println("failed to update ACLs", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s/settings", f.OwnerSlashRepo()))
+}
+
+func (s *State) SetCollaboratorRole(w http.ResponseWriter, r *http.Request) {
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	collaborator := r.FormValue("collaborator")
+	role := r.FormValue("role")
+	if collaborator == "" || !rbac.IsCollaboratorRole(role) {
+		http.Error(w, "malformed form", http.StatusBadRequest)
+		return
+	}
+
+	collaboratorIdent, err := s.resolver.ResolveIdent(r.Context(), collaborator)
+	if err != nil {
+		s.pages.Notice(w, "collaborators", "Failed to resolve collaborator.")
+		return
+	}
+	collaborator = collaboratorIdent.DID.String()
+
+	user := s.auth.GetUser(r)
+	current := s.enforcer.GetCollaboratorRole(collaborator, f.Knot, f.OwnerSlashRepo())
+	if current == "" {
+		s.pages.Notice(w, "collaborators", "Not a collaborator on this repository.")
+		return
+	}
+	if !s.enforcer.CanManageRole(user.Did, f.Knot, f.OwnerSlashRepo(), current) ||
+		!s.enforcer.CanManageRole(user.Did, f.Knot, f.OwnerSlashRepo(), role) {
+		s.pages.Notice(w, "collaborators", "You cannot grant or change a role higher than yours.")
+		return
+	}
+
+	secret, err := db.GetRegistrationKey(s.db, f.Knot)
+	if err != nil {
+		log.Printf("no key found for domain %s: %s\n", f.Knot, err)
+		s.pages.Notice(w, "collaborators", "Failed to change role.")
+		return
+	}
+
+	ksClient, err := NewSignedClient(f.Knot, secret, s.config.Dev)
+	if err != nil {
+		log.Println("failed to create client to ", f.Knot)
+		s.pages.Notice(w, "collaborators", "Failed to change role.")
+		return
+	}
+
+	ksResp, err := ksClient.SetCollaboratorRole(f.OwnerDid(), f.RepoName, collaborator, role)
+	if err != nil || ksResp.StatusCode != http.StatusNoContent {
+		log.Printf("failed to change collaborator role on %s: %v", f.Knot, err)
+		s.pages.Notice(w, "collaborators", "Failed to change role on knot.")
+		return
+	}
+
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Println("failed to start tx")
+		s.pages.Notice(w, "collaborators", "Failed to change role.")
+		return
+	}
+	defer func() {
+		tx.Rollback()
+		err = s.enforcer.E.LoadPolicy()
+		if err != nil {
+			log.Println("failed to rollback policies")
+		}
+	}()
+
+	err = s.enforcer.SetCollaboratorRole(collaborator, f.Knot, f.OwnerSlashRepo(), role)
+	if err != nil {
+		log.Println("failed to change collaborator role", err)
+		s.pages.Notice(w, "collaborators", "Failed to change role.")
+		return
+	}
+
+	err = db.UpdateCollaboratorRole(tx, collaborator, f.OwnerDid(), f.RepoName, f.Knot, role)
+	if err != nil {
+		log.Println("failed to change collaborator role", err)
+		s.pages.Notice(w, "collaborators", "Failed to change role.")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Println("failed to commit changes", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = s.enforcer.E.SavePolicy()
+	if err != nil {
+		log.Println("failed to update ACLs", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s/settings", f.OwnerSlashRepo()))
+}
+
+func (s *State) DeleteRepo(w http.ResponseWriter, r *http.Request) {
+	user := s.auth.GetUser(r)
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	secret, err := db.GetRegistrationKey(s.db, f.Knot)
+	if err != nil {
+		log.Printf("no key found for domain %s: %s\n", f.Knot, err)
+		s.pages.Notice(w, "delete-repo", "Failed to delete repository.")
+		return
+	}
+
+	ksClient, err := NewSignedClient(f.Knot, secret, s.config.Dev)
+	if err != nil {
+		log.Println("failed to create client to ", f.Knot)
+		s.pages.Notice(w, "delete-repo", "Failed to delete repository.")
+		return
+	}
+
+	ksResp, err := ksClient.RemoveRepo(f.OwnerDid(), f.RepoName)
+	if err != nil || ksResp.StatusCode != http.StatusNoContent {
+		log.Printf("failed to delete %s on %s: %v", f.OwnerSlashRepo(), f.Knot, err)
+		s.pages.Notice(w, "delete-repo", "Failed to delete repository on knot. Try again later.")
+		return
+	}
+
+	rkey := f.RepoAt.RecordKey().String()
+
+	// the record lives in the owner's PDS, so only they can remove it
+	if user.Did == f.OwnerDid() {
+		client, _ := s.auth.AuthorizedClient(r)
+		_, err = comatproto.RepoDeleteRecord(r.Context(), client, &comatproto.RepoDeleteRecord_Input{
+			Collection: tangled.RepoNSID,
+			Repo:       user.Did,
+			Rkey:       rkey,
+		})
+		if err != nil {
+			log.Println("failed to delete repo record", err)
+		}
+	}
+
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Println("failed to start tx")
+		s.pages.Notice(w, "delete-repo", "Failed to delete repository.")
+		return
+	}
+	defer func() {
+		tx.Rollback()
+		err = s.enforcer.E.LoadPolicy()
+		if err != nil {
+			log.Println("failed to rollback policies")
+		}
+	}()
+
+	// issues, pulls, stars, webhooks and collaborators go with it
+	err = db.RemoveRepo(tx, f.OwnerDid(), f.RepoName, rkey)
+	if err != nil {
+		log.Println("failed to remove repo", err)
+		s.pages.Notice(w, "delete-repo", "Failed to delete repository.")
+		return
+	}
+
+	err = s.enforcer.RemoveRepo(f.Knot, f.OwnerSlashRepo())
+	if err != nil {
+		log.Println("failed to remove repo policies", err)
+		s.pages.Notice(w, "delete-repo", "Failed to delete repository.")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Println("failed to commit changes", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = s.enforcer.E.SavePolicy()
+	if err != nil {
+		log.Println("failed to update ACLs", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s", f.OwnerDid()))
+}
+
 func (s *State) RepoSettings(w http.ResponseWriter, r *http.Request) {
 	f, err := fullyResolvedRepo(r)
 	if err != nil {
@@ -540,11 +1044,77 @@ func (s *State) RepoSettings(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		// only offer the roles this user may hand out
+		var assignableRoles []string
+		if user != nil {
+			for _, role := range rbac.CollaboratorRoles {
+				if s.enforcer.CanManageRole(user.Did, f.Knot, f.OwnerSlashRepo(), role) {
+					assignableRoles = append(assignableRoles, role)
+				}
+			}
+		}
+		for i, c := range repoCollaborators {
+			repoCollaborators[i].Manageable = isCollaboratorInviteAllowed && slices.Contains(assignableRoles, c.Role)
+		}
+
+		webhooks, err := db.GetWebhooks(s.db, f.RepoAt)
+		if err != nil {
+			log.Println("failed to get webhooks", err)
+		}
+
+		deliveries, err := db.GetRecentWebhookDeliveries(s.db, f.RepoAt, 20)
+		if err != nil {
+			log.Println("failed to get webhook deliveries", err)
+		}
+
+		branchRules, err := s.branchRules(f)
+		if err != nil {
+			log.Println("failed to get branch rules", err)
+		}
+
+		mirror, err := s.mirror(f)
+		if err != nil {
+			log.Println("failed to get mirror", err)
+		}
+
+		pushMirrors, err := s.pushMirrors(f)
+		if err != nil {
+			log.Println("failed to get push mirrors", err)
+		}
+
+		transfer, err := db.GetRepoTransfer(s.db, f.RepoAt.String())
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Println("failed to get transfer", err)
+		}
+
+		// other knots the owner could move this repo to
+		var knots []string
+		if user != nil && user.Did == f.OwnerDid() {
+			domains, err := s.enforcer.GetDomainsForUser(user.Did)
+			if err != nil {
+				log.Println("failed to get knots", err)
+			}
+			for _, d := range domains {
+				if d != f.Knot {
+					knots = append(knots, d)
+				}
+			}
+		}
+
 		s.pages.RepoSettings(w, pages.RepoSettingsParams{
 			LoggedInUser:                user,
 			RepoInfo:                    f.RepoInfo(s, user),
 			Collaborators:               repoCollaborators,
+			CollaboratorRoles:           assignableRoles,
 			IsCollaboratorInviteAllowed: isCollaboratorInviteAllowed,
+			Webhooks:                    webhooks,
+			WebhookDeliveries:           deliveries,
+			WebhookEvents:               db.WebhookEvents,
+			BranchRules:                 branchRules,
+			Mirror:                      mirror,
+			PushMirrors:                 pushMirrors,
+			Transfer:                    transfer,
+			Knots:                       knots,
 		})
 	}
 }
@@ -556,6 +1126,7 @@ type FullyResolvedRepo struct {
 	RepoAt      syntax.ATURI
 	Description string
 	AddedAt     string
+	Source      string
 }
 
 func (f *FullyResolvedRepo) OwnerDid() string {
@@ -579,18 +1150,16 @@ func (f *FullyResolvedRepo) Collaborators(ctx context.Context, s *State) ([]page
 
 	var collaborators []pages.Collaborator
 	for _, item := range repoCollaborators {
-		// currently only two roles: owner and member
 		var role string
+		did := item[0]
 		if item[3] == "repo:owner" {
 			role = "owner"
 		} else if item[3] == "repo:collaborator" {
-			role = "collaborator"
+			role = s.enforcer.GetCollaboratorRole(did, f.Knot, f.OwnerSlashRepo())
 		} else {
 			continue
 		}
 
-		did := item[0]
-
 		c := pages.Collaborator{
 			Did:    did,
 			Handle: "",
@@ -615,6 +1184,14 @@ func (f *FullyResolvedRepo) Collaborators(ctx context.Context, s *State) ([]page
 	return collaborators, nil
 }
 
+// isPrivate reports whether logged out users are kept out of the repo. The
+// issues, pulls and comments of private repos are kept in the appview only,
+// as records on a PDS are public.
+func (f *FullyResolvedRepo) isPrivate(s *State) bool {
+	isPublic, err := s.enforcer.IsReadAllowed("", f.Knot, f.OwnerSlashRepo())
+	return err != nil || !isPublic
+}
+
 func (f *FullyResolvedRepo) RepoInfo(s *State, u *auth.User) pages.RepoInfo {
 	isStarred := false
 	if u != nil {
@@ -629,6 +1206,20 @@ func (f *FullyResolvedRepo) RepoInfo(s *State, u *auth.User) pages.RepoInfo {
 	if err != nil {
 		log.Println("failed to get issue count for ", f.RepoAt)
 	}
+	pullCount, err := db.GetPullCount(s.db, f.RepoAt)
+	if err != nil {
+		log.Println("failed to get pull count for ", f.RepoAt)
+	}
+
+	isPublic, err := s.enforcer.IsReadAllowed("", f.Knot, f.OwnerSlashRepo())
+	if err != nil {
+		log.Println("failed to get visibility for ", f.RepoAt)
+	}
+
+	var source string
+	if f.Source != "" {
+		source = s.repoFullName(f.Source)
+	}
 
 	knot := f.Knot
 	if knot == "knot1.tangled.sh" {
@@ -642,11 +1233,14 @@ func (f *FullyResolvedRepo) RepoInfo(s *State, u *auth.User) pages.RepoInfo {
 		RepoAt:      f.RepoAt,
 		Description: f.Description,
 		IsStarred:   isStarred,
+		IsPrivate:   !isPublic,
+		Source:      source,
 		Knot:        knot,
 		Roles:       rolesInRepo(s, u, f),
 		Stats: db.RepoStats{
 			StarCount:  starCount,
 			IssueCount: issueCount,
+			PullCount:  pullCount,
 		},
 	}
 }
@@ -728,37 +1322,32 @@ func (s *State) CloseIssue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	collaborators, err := f.Collaborators(r.Context(), s)
-	if err != nil {
-		log.Println("failed to fetch repo collaborators: %w", err)
-	}
-	isCollaborator := slices.ContainsFunc(collaborators, func(collab pages.Collaborator) bool {
-		return user.Did == collab.Did
-	})
+	isTriager, _ := s.enforcer.IsTriageAllowed(user.Did, f.Knot, f.OwnerSlashRepo())
 	isIssueOwner := user.Did == issue.OwnerDid
 
-	// TODO: make this more granular
-	if isIssueOwner || isCollaborator {
-
-		closed := tangled.RepoIssueStateClosed
-
-		client, _ := s.auth.AuthorizedClient(r)
-		_, err = comatproto.RepoPutRecord(r.Context(), client, &comatproto.RepoPutRecord_Input{
-			Collection: tangled.RepoIssueStateNSID,
-			Repo:       issue.OwnerDid,
-			Rkey:       s.TID(),
-			Record: &lexutil.LexiconTypeDecoder{
-				Val: &tangled.RepoIssueState{
-					Issue: issue.IssueAt,
-					State: &closed,
+	if isIssueOwner || isTriager {
+
+		if !f.isPrivate(s) {
+			closed := tangled.RepoIssueStateClosed
+
+			client, _ := s.auth.AuthorizedClient(r)
+			_, err = comatproto.RepoPutRecord(r.Context(), client, &comatproto.RepoPutRecord_Input{
+				Collection: tangled.RepoIssueStateNSID,
+				Repo:       issue.OwnerDid,
+				Rkey:       s.TID(),
+				Record: &lexutil.LexiconTypeDecoder{
+					Val: &tangled.RepoIssueState{
+						Issue: issue.IssueAt,
+						State: &closed,
+					},
 				},
-			},
-		})
+			})
 
-		if err != nil {
-			log.Println("failed to update issue state", err)
-			s.pages.Notice(w, "issue-action", "Failed to close issue. Try again later.")
-			return
+			if err != nil {
+				log.Println("failed to update issue state", err)
+				s.pages.Notice(w, "issue-action", "Failed to close issue. Try again later.")
+				return
+			}
 		}
 
 		err := db.CloseIssue(s.db, f.RepoAt, issueIdInt)
@@ -768,6 +1357,11 @@ func (s *State) CloseIssue(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		s.queueWebhooks(f.webhookRepo(), db.WebhookIssueState, user.Did, map[string]any{
+			"issueId": issueIdInt,
+			"state":   "closed",
+		})
+
 		s.pages.HxLocation(w, fmt.Sprintf("/%s/issues/%d", f.OwnerSlashRepo(), issueIdInt))
 		return
 	} else {
@@ -793,17 +1387,33 @@ func (s *State) ReopenIssue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if user.Did == f.OwnerDid() {
+	issue, err := db.GetIssue(s.db, f.RepoAt, issueIdInt)
+	if err != nil {
+		log.Println("failed to get issue", err)
+		s.pages.Notice(w, "issue-action", "Failed to reopen issue. Try again later.")
+		return
+	}
+
+	isTriager, _ := s.enforcer.IsTriageAllowed(user.Did, f.Knot, f.OwnerSlashRepo())
+	isIssueOwner := user.Did == issue.OwnerDid
+
+	if isIssueOwner || isTriager {
 		err := db.ReopenIssue(s.db, f.RepoAt, issueIdInt)
 		if err != nil {
 			log.Println("failed to reopen issue", err)
 			s.pages.Notice(w, "issue-action", "Failed to reopen issue. Try again later.")
 			return
 		}
+
+		s.queueWebhooks(f.webhookRepo(), db.WebhookIssueState, user.Did, map[string]any{
+			"issueId": issueIdInt,
+			"state":   "open",
+		})
+
 		s.pages.HxLocation(w, fmt.Sprintf("/%s/issues/%d", f.OwnerSlashRepo(), issueIdInt))
 		return
 	} else {
-		log.Println("user is not the owner of the repo")
+		log.Println("user is not permitted to reopen issue")
 		http.Error(w, "forbidden", http.StatusUnauthorized)
 		return
 	}
@@ -848,39 +1458,47 @@ func (s *State) IssueComment(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		createdAt := time.Now().Format(time.RFC3339)
-		commentIdInt64 := int64(commentId)
-		ownerDid := user.Did
-		issueAt, err := db.GetIssueAt(s.db, f.RepoAt, issueIdInt)
-		if err != nil {
-			log.Println("failed to get issue at", err)
-			s.pages.Notice(w, "issue-comment", "Failed to create comment.")
-			return
-		}
+		if !f.isPrivate(s) {
+			createdAt := time.Now().Format(time.RFC3339)
+			commentIdInt64 := int64(commentId)
+			ownerDid := user.Did
+			issueAt, err := db.GetIssueAt(s.db, f.RepoAt, issueIdInt)
+			if err != nil {
+				log.Println("failed to get issue at", err)
+				s.pages.Notice(w, "issue-comment", "Failed to create comment.")
+				return
+			}
 
-		atUri := f.RepoAt.String()
-		client, _ := s.auth.AuthorizedClient(r)
-		_, err = comatproto.RepoPutRecord(r.Context(), client, &comatproto.RepoPutRecord_Input{
-			Collection: tangled.RepoIssueCommentNSID,
-			Repo:       user.Did,
-			Rkey:       s.TID(),
-			Record: &lexutil.LexiconTypeDecoder{
-				Val: &tangled.RepoIssueComment{
-					Repo:      &atUri,
-					Issue:     issueAt,
-					CommentId: &commentIdInt64,
-					Owner:     &ownerDid,
-					Body:      &body,
-					CreatedAt: &createdAt,
+			atUri := f.RepoAt.String()
+			client, _ := s.auth.AuthorizedClient(r)
+			_, err = comatproto.RepoPutRecord(r.Context(), client, &comatproto.RepoPutRecord_Input{
+				Collection: tangled.RepoIssueCommentNSID,
+				Repo:       user.Did,
+				Rkey:       s.TID(),
+				Record: &lexutil.LexiconTypeDecoder{
+					Val: &tangled.RepoIssueComment{
+						Repo:      &atUri,
+						Issue:     issueAt,
+						CommentId: &commentIdInt64,
+						Owner:     &ownerDid,
+						Body:      &body,
+						CreatedAt: &createdAt,
+					},
 				},
-			},
-		})
-		if err != nil {
-			log.Println("failed to create comment", err)
-			s.pages.Notice(w, "issue-comment", "Failed to create comment.")
-			return
+			})
+			if err != nil {
+				log.Println("failed to create comment", err)
+				s.pages.Notice(w, "issue-comment", "Failed to create comment.")
+				return
+			}
 		}
 
+		s.queueWebhooks(f.webhookRepo(), db.WebhookIssueComment, user.Did, map[string]any{
+			"issueId":   issueIdInt,
+			"commentId": commentId,
+			"body":      body,
+		})
+
 		s.pages.HxLocation(w, fmt.Sprintf("/%s/issues/%d#comment-%d", f.OwnerSlashRepo(), issueIdInt, commentId))
 		return
 	}
@@ -986,55 +1604,49 @@ func (s *State) NewIssue(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		client, _ := s.auth.AuthorizedClient(r)
-		atUri := f.RepoAt.String()
-		resp, err := comatproto.RepoPutRecord(r.Context(), client, &comatproto.RepoPutRecord_Input{
-			Collection: tangled.RepoIssueNSID,
-			Repo:       user.Did,
-			Rkey:       s.TID(),
-			Record: &lexutil.LexiconTypeDecoder{
-				Val: &tangled.RepoIssue{
-					Repo:    atUri,
-					Title:   title,
-					Body:    &body,
-					Owner:   user.Did,
-					IssueId: int64(issueId),
+		var issueAt string
+		if !f.isPrivate(s) {
+			client, _ := s.auth.AuthorizedClient(r)
+			atUri := f.RepoAt.String()
+			resp, err := comatproto.RepoPutRecord(r.Context(), client, &comatproto.RepoPutRecord_Input{
+				Collection: tangled.RepoIssueNSID,
+				Repo:       user.Did,
+				Rkey:       s.TID(),
+				Record: &lexutil.LexiconTypeDecoder{
+					Val: &tangled.RepoIssue{
+						Repo:    atUri,
+						Title:   title,
+						Body:    &body,
+						Owner:   user.Did,
+						IssueId: int64(issueId),
+					},
 				},
-			},
-		})
-		if err != nil {
-			log.Println("failed to create issue", err)
-			s.pages.Notice(w, "issues", "Failed to create issue.")
-			return
-		}
+			})
+			if err != nil {
+				log.Println("failed to create issue", err)
+				s.pages.Notice(w, "issues", "Failed to create issue.")
+				return
+			}
+			issueAt = resp.Uri
 
-		err = db.SetIssueAt(s.db, f.RepoAt, issueId, resp.Uri)
-		if err != nil {
-			log.Println("failed to set issue at", err)
-			s.pages.Notice(w, "issues", "Failed to create issue.")
-			return
+			err = db.SetIssueAt(s.db, f.RepoAt, issueId, issueAt)
+			if err != nil {
+				log.Println("failed to set issue at", err)
+				s.pages.Notice(w, "issues", "Failed to create issue.")
+				return
+			}
 		}
 
-		s.pages.HxLocation(w, fmt.Sprintf("/%s/issues/%d", f.OwnerSlashRepo(), issueId))
-		return
-	}
-}
+		s.queueWebhooks(f.webhookRepo(), db.WebhookIssue, user.Did, map[string]any{
+			"issueId": issueId,
+			"issueAt": issueAt,
+			"title":   title,
+			"body":    body,
+		})
 
-func (s *State) RepoPulls(w http.ResponseWriter, r *http.Request) {
-	user := s.auth.GetUser(r)
-	f, err := fullyResolvedRepo(r)
-	if err != nil {
-		log.Println("failed to get repo and knot", err)
+		s.pages.HxLocation(w, fmt.Sprintf("/%s/issues/%d", f.OwnerSlashRepo(), issueId))
 		return
 	}
-
-	switch r.Method {
-	case http.MethodGet:
-		s.pages.RepoPulls(w, pages.RepoPullsParams{
-			LoggedInUser: user,
-			RepoInfo:     f.RepoInfo(s, user),
-		})
-	}
 }
 
 func fullyResolvedRepo(r *http.Request) (*FullyResolvedRepo, error) {
@@ -1065,6 +1677,7 @@ func fullyResolvedRepo(r *http.Request) (*FullyResolvedRepo, error) {
 	// pass through values from the middleware
 	description, ok := r.Context().Value("repoDescription").(string)
 	addedAt, ok := r.Context().Value("repoAddedAt").(string)
+	source, ok := r.Context().Value("repoSource").(string)
 
 	return &FullyResolvedRepo{
 		Knot:        knot,
@@ -1073,6 +1686,7 @@ func fullyResolvedRepo(r *http.Request) (*FullyResolvedRepo, error) {
 		RepoAt:      parsedRepoAt,
 		Description: description,
 		AddedAt:     addedAt,
+		Source:      source,
 	}, nil
 }
 
@@ -1084,3 +1698,25 @@ func rolesInRepo(s *State, u *auth.User, f *FullyResolvedRepo) pages.RolesInRepo
 		return pages.RolesInRepo{}
 	}
 }
+
+// canRead reports whether u, or a logged out visitor if u is nil, may see repo.
+func (s *State) canRead(u *auth.User, repo *db.Repo) bool {
+	var did string
+	if u != nil {
+		did = u.Did
+	}
+
+	p, _ := securejoin.SecureJoin(repo.Did, repo.Name)
+	ok, err := s.enforcer.IsReadAllowed(did, repo.Knot, p)
+	if err != nil {
+		log.Println("failed to check read access", err)
+		return false
+	}
+	return ok
+}
+
+func (s *State) readableRepos(u *auth.User, repos []db.Repo) []db.Repo {
+	return slices.DeleteFunc(repos, func(repo db.Repo) bool {
+		return !s.canRead(u, &repo)
+	})
+}
@@ -1,6 +1,9 @@
 package state
 
 import (
+	"encoding/json"
+	"fmt"
+	"html/template"
 	"log"
 	"net/http"
 	"strings"
@@ -15,16 +18,21 @@ import (
 )
 
 func (s *State) Settings(w http.ResponseWriter, r *http.Request) {
-	// for now, this is just pubkeys
 	user := s.auth.GetUser(r)
 	pubKeys, err := db.GetPublicKeys(s.db, user.Did)
 	if err != nil {
 		log.Println(err)
 	}
 
+	appPasswords, err := db.GetAppPasswords(s.db, user.Did)
+	if err != nil {
+		log.Println(err)
+	}
+
 	s.pages.Settings(w, pages.SettingsParams{
 		LoggedInUser: user,
 		PubKeys:      pubKeys,
+		AppPasswords: appPasswords,
 	})
 }
 
@@ -78,3 +86,71 @@ func (s *State) SettingsKeys(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+func (s *State) SettingsAppPasswords(w http.ResponseWriter, r *http.Request) {
+	did := s.auth.GetDid(r)
+
+	switch r.Method {
+	case http.MethodPut:
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" {
+			s.pages.Notice(w, "settings-app-passwords", "Name cannot be empty.")
+			return
+		}
+
+		token, err := db.GenerateAppPassword(s.db, did, name)
+		if err != nil {
+			log.Printf("generating app password: %s", err)
+			s.pages.Notice(w, "settings-app-passwords", "Failed to create app password. Is the name already in use?")
+			return
+		}
+
+		s.pages.Notice(w, "settings-app-passwords", fmt.Sprintf(
+			"Created <strong>%s</strong>. Copy it now, it will not be shown again:<code class=\"block break-all\">%s</code>",
+			template.HTMLEscapeString(name), token,
+		))
+		return
+	case http.MethodDelete:
+		name := r.FormValue("name")
+		if err := db.RemoveAppPassword(s.db, did, name); err != nil {
+			log.Printf("removing app password: %s", err)
+			s.pages.Notice(w, "settings-app-passwords", "Failed to remove app password.")
+			return
+		}
+
+		s.pages.HxLocation(w, "/settings")
+		return
+	}
+}
+
+// VerifyAppPassword is used by knots to authenticate git operations over
+// HTTP. It expects a user (handle or DID) and an app password, and responds
+// with the resolved DID if they match.
+func (s *State) VerifyAppPassword(w http.ResponseWriter, r *http.Request) {
+	user := strings.TrimPrefix(r.FormValue("user"), "@")
+	password := r.FormValue("password")
+	if user == "" || password == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id, err := s.resolver.ResolveIdent(r.Context(), user)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	ok, err := db.CheckAppPassword(s.db, id.DID.String(), password)
+	if err != nil {
+		log.Printf("checking app password: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"did": id.DID.String()})
+}
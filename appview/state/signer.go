@@ -7,9 +7,14 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
+
+	"github.com/sotangled/tangled/appview/db"
+	"github.com/sotangled/tangled/types"
 )
 
 type SignerTransport struct {
@@ -27,6 +32,41 @@ func (s SignerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return http.DefaultTransport.RoundTrip(req)
 }
 
+// verifySignature checks a request signed by SignerTransport with secret.
+func verifySignature(secret string, r *http.Request) bool {
+	timestamp := r.Header.Get("X-Timestamp")
+	reqTime, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil || time.Since(reqTime) > time.Minute {
+		return false
+	}
+
+	signature, err := hex.DecodeString(r.Header.Get("X-Signature"))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(r.Method + r.URL.Path + timestamp))
+
+	return hmac.Equal(signature, mac.Sum(nil))
+}
+
+// knotClient returns a client for reading from knot. Requests are signed with
+// the knot's registration key, if there is one, so that the knot lets us read
+// private repos.
+func (s *State) knotClient(knot string) *http.Client {
+	secret, err := db.GetRegistrationKey(s.db, knot)
+	if err != nil || secret == "" {
+		return http.DefaultClient
+	}
+
+	return &http.Client{
+		Transport: SignerTransport{
+			Secret: secret,
+		},
+	}
+}
+
 type SignedClient struct {
 	Secret string
 	Url    *url.URL
@@ -81,7 +121,7 @@ func (s *SignedClient) Init(did string) (*http.Response, error) {
 	return s.client.Do(req)
 }
 
-func (s *SignedClient) NewRepo(did, repoName, defaultBranch string) (*http.Response, error) {
+func (s *SignedClient) NewRepo(did, repoName, defaultBranch string, private bool) (*http.Response, error) {
 	const (
 		Method   = "PUT"
 		Endpoint = "/repo/new"
@@ -91,6 +131,131 @@ func (s *SignedClient) NewRepo(did, repoName, defaultBranch string) (*http.Respo
 		"did":            did,
 		"name":           repoName,
 		"default_branch": defaultBranch,
+		"private":        private,
+	})
+
+	req, err := s.newRequest(Method, Endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Do(req)
+}
+
+func (s *SignedClient) ForkRepo(did, repoName, source string) (*http.Response, error) {
+	const (
+		Method   = "PUT"
+		Endpoint = "/repo/fork"
+	)
+
+	body, _ := json.Marshal(map[string]any{
+		"did":    did,
+		"name":   repoName,
+		"source": source,
+	})
+
+	req, err := s.newRequest(Method, Endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Do(req)
+}
+
+func (s *SignedClient) ImportRepo(did, repoName, source string, private, mirror bool) (*http.Response, error) {
+	const (
+		Method   = "PUT"
+		Endpoint = "/repo/import"
+	)
+
+	body, _ := json.Marshal(map[string]any{
+		"did":     did,
+		"name":    repoName,
+		"source":  source,
+		"private": private,
+		"mirror":  mirror,
+	})
+
+	req, err := s.newRequest(Method, Endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Do(req)
+}
+
+func (s *SignedClient) SetMirror(ownerDid, repoName, source string, interval int) (*http.Response, error) {
+	const (
+		Method   = "PUT"
+		Endpoint = "/repo/mirror"
+	)
+
+	body, _ := json.Marshal(map[string]any{
+		"did":      ownerDid,
+		"name":     repoName,
+		"source":   source,
+		"interval": interval,
+	})
+
+	req, err := s.newRequest(Method, Endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Do(req)
+}
+
+func (s *SignedClient) RemoveMirror(ownerDid, repoName string) (*http.Response, error) {
+	const (
+		Method   = "DELETE"
+		Endpoint = "/repo/mirror"
+	)
+
+	body, _ := json.Marshal(map[string]any{
+		"did":  ownerDid,
+		"name": repoName,
+	})
+
+	req, err := s.newRequest(Method, Endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Do(req)
+}
+
+func (s *SignedClient) AddPushMirror(ownerDid, repoName, url, username, password string) (*http.Response, error) {
+	const (
+		Method   = "PUT"
+		Endpoint = "/repo/push-mirrors"
+	)
+
+	body, _ := json.Marshal(map[string]any{
+		"did":      ownerDid,
+		"name":     repoName,
+		"url":      url,
+		"username": username,
+		"password": password,
+	})
+
+	req, err := s.newRequest(Method, Endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Do(req)
+}
+
+func (s *SignedClient) RemovePushMirror(ownerDid, repoName string, id int64) (*http.Response, error) {
+	const (
+		Method   = "DELETE"
+		Endpoint = "/repo/push-mirrors"
+	)
+
+	body, _ := json.Marshal(map[string]any{
+		"did":  ownerDid,
+		"name": repoName,
+		"id":   id,
 	})
 
 	req, err := s.newRequest(Method, Endpoint, body)
@@ -120,6 +285,53 @@ func (s *SignedClient) RemoveRepo(did, repoName string) (*http.Response, error)
 	return s.client.Do(req)
 }
 
+func (s *SignedClient) RenameRepo(did, repoName, newDid, newName string) (*http.Response, error) {
+	const (
+		Method   = "POST"
+		Endpoint = "/repo/rename"
+	)
+
+	body, _ := json.Marshal(map[string]any{
+		"did":      did,
+		"name":     repoName,
+		"new_did":  newDid,
+		"new_name": newName,
+	})
+
+	req, err := s.newRequest(Method, Endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Do(req)
+}
+
+// ImportBundle creates a repo on the knot from a bundle fetched off another
+// knot. Bundles can be large, so unlike the other calls this one is not
+// bound by the client timeout.
+func (s *SignedClient) ImportBundle(did, repoName string, private bool, bundle io.Reader) (*http.Response, error) {
+	const (
+		Method   = "PUT"
+		Endpoint = "/repo/bundle"
+	)
+
+	u := s.Url.JoinPath(Endpoint)
+	q := u.Query()
+	q.Set("did", did)
+	q.Set("name", repoName)
+	q.Set("private", strconv.FormatBool(private))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(Method, u.String(), bundle)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-git-bundle")
+
+	client := &http.Client{Transport: s.client.Transport}
+	return client.Do(req)
+}
+
 func (s *SignedClient) AddMember(did string) (*http.Response, error) {
 	const (
 		Method   = "PUT"
@@ -138,6 +350,24 @@ func (s *SignedClient) AddMember(did string) (*http.Response, error) {
 	return s.client.Do(req)
 }
 
+func (s *SignedClient) RemoveMember(did string) (*http.Response, error) {
+	const (
+		Method   = "DELETE"
+		Endpoint = "/member/remove"
+	)
+
+	body, _ := json.Marshal(map[string]any{
+		"did": did,
+	})
+
+	req, err := s.newRequest(Method, Endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Do(req)
+}
+
 func (s *SignedClient) AddCollaborator(ownerDid, repoName, memberDid string) (*http.Response, error) {
 	const (
 		Method = "POST"
@@ -155,3 +385,140 @@ func (s *SignedClient) AddCollaborator(ownerDid, repoName, memberDid string) (*h
 
 	return s.client.Do(req)
 }
+
+func (s *SignedClient) RemoveCollaborator(ownerDid, repoName, memberDid string) (*http.Response, error) {
+	const (
+		Method = "POST"
+	)
+	endpoint := fmt.Sprintf("/%s/%s/collaborator/remove", ownerDid, repoName)
+
+	body, _ := json.Marshal(map[string]any{
+		"did": memberDid,
+	})
+
+	req, err := s.newRequest(Method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Do(req)
+}
+
+func (s *SignedClient) SetCollaboratorRole(ownerDid, repoName, memberDid, role string) (*http.Response, error) {
+	const (
+		Method = "POST"
+	)
+	endpoint := fmt.Sprintf("/%s/%s/collaborator/role", ownerDid, repoName)
+
+	body, _ := json.Marshal(map[string]any{
+		"did":  memberDid,
+		"role": role,
+	})
+
+	req, err := s.newRequest(Method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Do(req)
+}
+
+type MergeRequest struct {
+	Patch          []byte
+	Branch         string
+	AuthorName     string
+	AuthorEmail    string
+	CommitterName  string
+	CommitterEmail string
+	CommitMessage  string
+	// Pusher is the DID of the user merging, checked against branch rules.
+	Pusher string
+}
+
+func (s *SignedClient) Merge(ownerDid, repoName string, m MergeRequest) (*http.Response, error) {
+	const (
+		Method   = "POST"
+		Endpoint = "/repo/merge"
+	)
+
+	body, _ := json.Marshal(map[string]any{
+		"did":            ownerDid,
+		"name":           repoName,
+		"patch":          string(m.Patch),
+		"branch":         m.Branch,
+		"authorName":     m.AuthorName,
+		"authorEmail":    m.AuthorEmail,
+		"committerName":  m.CommitterName,
+		"committerEmail": m.CommitterEmail,
+		"commitMessage":  m.CommitMessage,
+		"pusher":         m.Pusher,
+	})
+
+	req, err := s.newRequest(Method, Endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Do(req)
+}
+
+func (s *SignedClient) MergeCheck(patch []byte, ownerDid, repoName, branch string) (*http.Response, error) {
+	const (
+		Method   = "POST"
+		Endpoint = "/repo/merge/check"
+	)
+
+	body, _ := json.Marshal(map[string]any{
+		"did":    ownerDid,
+		"name":   repoName,
+		"patch":  string(patch),
+		"branch": branch,
+	})
+
+	req, err := s.newRequest(Method, Endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Do(req)
+}
+
+func (s *SignedClient) AddBranchRule(ownerDid, repoName string, rule types.BranchRule) (*http.Response, error) {
+	const (
+		Method   = "PUT"
+		Endpoint = "/repo/branch-rules"
+	)
+
+	body, _ := json.Marshal(map[string]any{
+		"did":  ownerDid,
+		"name": repoName,
+		"rule": rule,
+	})
+
+	req, err := s.newRequest(Method, Endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Do(req)
+}
+
+func (s *SignedClient) RemoveBranchRule(ownerDid, repoName string, id int64) (*http.Response, error) {
+	const (
+		Method   = "DELETE"
+		Endpoint = "/repo/branch-rules"
+	)
+
+	body, _ := json.Marshal(map[string]any{
+		"did":  ownerDid,
+		"name": repoName,
+		"id":   id,
+	})
+
+	req, err := s.newRequest(Method, Endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Do(req)
+}
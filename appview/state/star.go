@@ -62,6 +62,8 @@ func (s *State) Star(w http.ResponseWriter, r *http.Request) {
 
 		log.Println("created atproto record: ", resp.Uri)
 
+		s.queueStarWebhook(currentUser.Did, subjectUri, "starred")
+
 		s.pages.StarFragment(w, pages.StarFragmentParams{
 			IsStarred: true,
 			RepoAt:    subjectUri,
@@ -96,6 +98,8 @@ func (s *State) Star(w http.ResponseWriter, r *http.Request) {
 			// this is not an issue, the firehose event might have already done this
 		}
 
+		s.queueStarWebhook(currentUser.Did, subjectUri, "unstarred")
+
 		starCount, err := db.GetStarCount(s.db, subjectUri)
 		if err != nil {
 			log.Println("failed to get star count for ", subjectUri)
@@ -113,3 +117,20 @@ func (s *State) Star(w http.ResponseWriter, r *http.Request) {
 	}
 
 }
+
+func (s *State) queueStarWebhook(sender string, subject syntax.ATURI, action string) {
+	repo, err := db.GetRepoByAtUri(s.db, subject.String())
+	if err != nil {
+		log.Println("failed to get starred repo", err)
+		return
+	}
+
+	s.queueWebhooks(WebhookRepo{
+		Did:   repo.Did,
+		Name:  repo.Name,
+		Knot:  repo.Knot,
+		AtUri: repo.AtUri,
+	}, db.WebhookStar, sender, map[string]string{
+		"action": action,
+	})
+}
@@ -10,12 +10,14 @@ import (
 	"log"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
 	comatproto "github.com/bluesky-social/indigo/api/atproto"
 	"github.com/bluesky-social/indigo/atproto/syntax"
 	lexutil "github.com/bluesky-social/indigo/lex/util"
+	"github.com/bluesky-social/indigo/xrpc"
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/go-chi/chi/v5"
 	tangled "github.com/sotangled/tangled/api/tangled"
@@ -54,6 +56,16 @@ func Make(config *appview.Config) (*State, error) {
 		return nil, err
 	}
 
+	// repos created before repo:read existed are public
+	err = enforcer.BackfillReadPolicies()
+	if err != nil {
+		return nil, fmt.Errorf("failed to backfill read policies: %w", err)
+	}
+	err = enforcer.BackfillRoles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to backfill roles: %w", err)
+	}
+
 	clock := syntax.NewTIDClock(0)
 
 	pgs := pages.NewPages()
@@ -81,6 +93,8 @@ func Make(config *appview.Config) (*State, error) {
 		config,
 	}
 
+	go state.deliverWebhooks(context.Background())
+
 	return state, nil
 }
 
@@ -178,6 +192,16 @@ func (s *State) Timeline(w http.ResponseWriter, r *http.Request) {
 		s.pages.Notice(w, "timeline", "Uh oh! Failed to load timeline.")
 	}
 
+	timeline = slices.DeleteFunc(timeline, func(ev db.TimelineEvent) bool {
+		if ev.Repo != nil {
+			return !s.canRead(user, ev.Repo)
+		}
+		if ev.Star != nil && ev.Star.Repo != nil {
+			return !s.canRead(user, ev.Star.Repo)
+		}
+		return false
+	})
+
 	var didsToResolve []string
 	for _, ev := range timeline {
 		if ev.Repo != nil {
@@ -543,7 +567,118 @@ func (s *State) AddMember(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(fmt.Sprint("added member: ", memberIdent.Handle.String())))
 }
 
+// remove member from domain, requires auth and requires owner status
 func (s *State) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	domain := chi.URLParam(r, "domain")
+	if domain == "" {
+		http.Error(w, "malformed url", http.StatusBadRequest)
+		return
+	}
+
+	memberDid := r.FormValue("member")
+	if memberDid == "" {
+		http.Error(w, "malformed form", http.StatusBadRequest)
+		return
+	}
+
+	memberIdent, err := s.resolver.ResolveIdent(r.Context(), memberDid)
+	if err != nil {
+		w.Write([]byte("failed to resolve member did to a handle"))
+		return
+	}
+	member := memberIdent.DID.String()
+
+	if ok, _ := s.enforcer.IsServerOwner(member, domain); ok {
+		w.Write([]byte("cannot remove the knot owner"))
+		return
+	}
+	log.Printf("removing %s from %s\n", memberIdent.Handle.String(), domain)
+
+	secret, err := db.GetRegistrationKey(s.db, domain)
+	if err != nil {
+		log.Printf("no key found for domain %s: %s\n", domain, err)
+		return
+	}
+
+	ksClient, err := NewSignedClient(domain, secret, s.config.Dev)
+	if err != nil {
+		log.Println("failed to create client to ", domain)
+		return
+	}
+
+	ksResp, err := ksClient.RemoveMember(member)
+	if err != nil {
+		log.Printf("failed to make request to %s: %s", domain, err)
+		return
+	}
+
+	if ksResp.StatusCode != http.StatusNoContent {
+		w.Write([]byte(fmt.Sprint("knotserver failed to remove member: ", ksResp.Status)))
+		return
+	}
+
+	// retract the membership records published by AddMember
+	client, _ := s.auth.AuthorizedClient(r)
+	currentUser := s.auth.GetUser(r)
+	if err := s.deleteMemberRecords(r.Context(), client, currentUser.Did, domain, member); err != nil {
+		log.Printf("failed to delete member records: %s", err)
+	}
+
+	err = s.enforcer.RemoveMember(domain, member)
+	if err != nil {
+		w.Write([]byte(fmt.Sprint("failed to remove member: ", err)))
+		return
+	}
+
+	err = db.RemoveCollaboratorFromKnot(s.db, member, domain)
+	if err != nil {
+		log.Println("failed to remove collaborations", err)
+	}
+
+	err = s.enforcer.E.SavePolicy()
+	if err != nil {
+		log.Println("failed to update ACLs", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/knots/%s", domain))
+}
+
+func (s *State) deleteMemberRecords(ctx context.Context, client *xrpc.Client, ownerDid, domain, member string) error {
+	cursor := ""
+	for {
+		out, err := comatproto.RepoListRecords(ctx, client, tangled.KnotMemberNSID, cursor, 100, ownerDid, false, "", "")
+		if err != nil {
+			return err
+		}
+
+		for _, rec := range out.Records {
+			km, ok := rec.Value.Val.(*tangled.KnotMember)
+			if !ok || km.Domain != domain || km.Member != member {
+				continue
+			}
+
+			uri, err := syntax.ParseATURI(rec.Uri)
+			if err != nil {
+				return err
+			}
+
+			_, err = comatproto.RepoDeleteRecord(ctx, client, &comatproto.RepoDeleteRecord_Input{
+				Collection: tangled.KnotMemberNSID,
+				Repo:       ownerDid,
+				Rkey:       uri.RecordKey().String(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if out.Cursor == nil || *out.Cursor == "" || len(out.Records) == 0 {
+			return nil
+		}
+		cursor = *out.Cursor
+	}
 }
 
 func (s *State) NewRepo(w http.ResponseWriter, r *http.Request) {
@@ -582,6 +717,7 @@ func (s *State) NewRepo(w http.ResponseWriter, r *http.Request) {
 		}
 
 		description := r.FormValue("description")
+		private := r.FormValue("private") == "on"
 
 		ok, err := s.enforcer.E.Enforce(user.Did, domain, domain, "repo:create")
 		if err != nil || !ok {
@@ -652,7 +788,7 @@ func (s *State) NewRepo(w http.ResponseWriter, r *http.Request) {
 			}
 		}()
 
-		resp, err := client.NewRepo(user.Did, repoName, defaultBranch)
+		resp, err := client.NewRepo(user.Did, repoName, defaultBranch, private)
 		if err != nil {
 			s.pages.Notice(w, "repo", "Failed to create repository on knot server.")
 			return
@@ -685,6 +821,15 @@ func (s *State) NewRepo(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if !private {
+			err = s.enforcer.MakeRepoPublic(domain, p)
+			if err != nil {
+				log.Println(err)
+				s.pages.Notice(w, "repo", "Failed to set up repository permissions.")
+				return
+			}
+		}
+
 		err = tx.Commit()
 		if err != nil {
 			log.Println("failed to commit changes", err)
@@ -727,6 +872,11 @@ func (s *State) ProfilePage(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Printf("getting collaborating repos for %s: %s", ident.DID.String(), err)
 	}
+
+	loggedInUser := s.auth.GetUser(r)
+	repos = s.readableRepos(loggedInUser, repos)
+	collaboratingRepos = s.readableRepos(loggedInUser, collaboratingRepos)
+
 	var didsToResolve []string
 	for _, r := range collaboratingRepos {
 		didsToResolve = append(didsToResolve, r.Did)
@@ -746,7 +896,6 @@ func (s *State) ProfilePage(w http.ResponseWriter, r *http.Request) {
 		log.Printf("getting follow stats repos for %s: %s", ident.DID.String(), err)
 	}
 
-	loggedInUser := s.auth.GetUser(r)
 	followStatus := db.IsNotFollowing
 	if loggedInUser != nil {
 		followStatus = db.GetFollowStatus(s.db, loggedInUser.Did, ident.DID.String())
@@ -842,51 +991,100 @@ func (s *State) UserRouter() http.Handler {
 	r.With(ResolveIdent(s)).Route("/{user}", func(r chi.Router) {
 		r.Get("/", s.ProfilePage)
 		r.With(ResolveRepoKnot(s)).Route("/{repo}", func(r chi.Router) {
-			r.Get("/", s.RepoIndex)
-			r.Get("/commits/{ref}", s.RepoLog)
-			r.Route("/tree/{ref}", func(r chi.Router) {
-				r.Get("/", s.RepoIndex)
-				r.Get("/*", s.RepoTree)
+			// These routes get proxied to the knot, which does its own auth
+			r.Get("/info/refs", s.InfoRefs)
+			r.Post("/git-upload-pack", s.UploadPack)
+			r.Post("/git-receive-pack", s.ReceivePack)
+
+			// the recipient of a transfer may not be able to read a private repo yet
+			r.With(AuthMiddleware(s)).Route("/transfer", func(r chi.Router) {
+				r.Get("/", s.AcceptRepoTransfer)
+				r.Post("/", s.AcceptRepoTransfer)
 			})
-			r.Get("/commit/{ref}", s.RepoCommit)
-			r.Get("/branches", s.RepoBranches)
-			r.Get("/tags", s.RepoTags)
-			r.Get("/blob/{ref}/*", s.RepoBlob)
 
-			r.Route("/issues", func(r chi.Router) {
-				r.Get("/", s.RepoIssues)
-				r.Get("/{issue}", s.RepoSingleIssue)
+			r.Group(func(r chi.Router) {
+				r.Use(RepoReadMiddleware(s))
 
-				r.Group(func(r chi.Router) {
-					r.Use(AuthMiddleware(s))
-					r.Get("/new", s.NewIssue)
-					r.Post("/new", s.NewIssue)
-					r.Post("/{issue}/comment", s.IssueComment)
-					r.Post("/{issue}/close", s.CloseIssue)
-					r.Post("/{issue}/reopen", s.ReopenIssue)
+				r.Get("/", s.RepoIndex)
+				r.Get("/commits/{ref}", s.RepoLog)
+				r.Get("/history/{ref}/*", s.RepoHistory)
+				r.Route("/tree/{ref}", func(r chi.Router) {
+					r.Get("/", s.RepoIndex)
+					r.Get("/*", s.RepoTree)
+				})
+				r.Get("/commit/{ref}", s.RepoCommit)
+				r.Get("/compare", s.RepoCompare)
+				r.Get("/compare/*", s.RepoCompare)
+				r.Get("/branches", s.RepoBranches)
+				r.Get("/tags", s.RepoTags)
+				r.Get("/blob/{ref}/*", s.RepoBlob)
+				r.Get("/blame/{ref}/*", s.RepoBlame)
+				r.Get("/raw/{ref}/*", s.RepoRaw)
+
+				r.Get("/import", s.RepoImportStatus)
+
+				r.With(AuthMiddleware(s)).Route("/fork", func(r chi.Router) {
+					r.Get("/", s.ForkRepo)
+					r.Post("/", s.ForkRepo)
 				})
-			})
-
-			r.Route("/pulls", func(r chi.Router) {
-				r.Get("/", s.RepoPulls)
-			})
 
-			// These routes get proxied to the knot
-			r.Get("/info/refs", s.InfoRefs)
-			r.Post("/git-upload-pack", s.UploadPack)
+				r.Route("/issues", func(r chi.Router) {
+					r.Get("/", s.RepoIssues)
+					r.Get("/{issue}", s.RepoSingleIssue)
+
+					r.Group(func(r chi.Router) {
+						r.Use(AuthMiddleware(s))
+						r.Get("/new", s.NewIssue)
+						r.Post("/new", s.NewIssue)
+						r.Post("/{issue}/comment", s.IssueComment)
+						r.Post("/{issue}/close", s.CloseIssue)
+						r.Post("/{issue}/reopen", s.ReopenIssue)
+					})
+				})
 
-			// settings routes, needs auth
-			r.Group(func(r chi.Router) {
-				r.Use(AuthMiddleware(s))
-				// repo description can only be edited by owner
-				r.With(RepoPermissionMiddleware(s, "repo:owner")).Route("/description", func(r chi.Router) {
-					r.Put("/", s.RepoDescription)
-					r.Get("/", s.RepoDescription)
-					r.Get("/edit", s.RepoDescriptionEdit)
+				r.Route("/pulls", func(r chi.Router) {
+					r.Get("/", s.RepoPulls)
+					r.Get("/{pull}", s.RepoSinglePull)
+
+					r.Group(func(r chi.Router) {
+						r.Use(AuthMiddleware(s))
+						r.Get("/new", s.NewPull)
+						r.Post("/new", s.NewPull)
+						r.Post("/{pull}/comment", s.PullComment)
+						r.Post("/{pull}/close", s.ClosePull)
+						r.Post("/{pull}/reopen", s.ReopenPull)
+						r.With(RepoPermissionMiddleware(s, "repo:push")).Post("/{pull}/merge", s.MergePull)
+					})
 				})
-				r.With(RepoPermissionMiddleware(s, "repo:settings")).Route("/settings", func(r chi.Router) {
-					r.Get("/", s.RepoSettings)
-					r.With(RepoPermissionMiddleware(s, "repo:invite")).Put("/collaborator", s.AddCollaborator)
+
+				// settings routes, needs auth
+				r.Group(func(r chi.Router) {
+					r.Use(AuthMiddleware(s))
+					// repo description can only be edited by owner
+					r.With(RepoPermissionMiddleware(s, "repo:owner")).Route("/description", func(r chi.Router) {
+						r.Put("/", s.RepoDescription)
+						r.Get("/", s.RepoDescription)
+						r.Get("/edit", s.RepoDescriptionEdit)
+					})
+					r.With(RepoPermissionMiddleware(s, "repo:settings")).Route("/settings", func(r chi.Router) {
+						r.Get("/", s.RepoSettings)
+						r.With(RepoPermissionMiddleware(s, "repo:invite")).Put("/collaborator", s.AddCollaborator)
+						r.With(RepoPermissionMiddleware(s, "repo:invite")).Delete("/collaborator", s.RemoveCollaborator)
+						r.With(RepoPermissionMiddleware(s, "repo:invite")).Post("/collaborator/role", s.SetCollaboratorRole)
+						r.With(RepoPermissionMiddleware(s, "repo:owner")).Put("/webhooks", s.AddWebhook)
+						r.With(RepoPermissionMiddleware(s, "repo:owner")).Delete("/webhooks/{id}", s.DeleteWebhook)
+						r.With(RepoPermissionMiddleware(s, "repo:owner")).Put("/branch-rules", s.AddBranchRule)
+						r.With(RepoPermissionMiddleware(s, "repo:owner")).Delete("/branch-rules/{id}", s.RemoveBranchRule)
+						r.With(RepoPermissionMiddleware(s, "repo:owner")).Put("/mirror", s.SetMirror)
+						r.With(RepoPermissionMiddleware(s, "repo:owner")).Delete("/mirror", s.RemoveMirror)
+						r.With(RepoPermissionMiddleware(s, "repo:owner")).Put("/push-mirrors", s.AddPushMirror)
+						r.With(RepoPermissionMiddleware(s, "repo:owner")).Delete("/push-mirrors/{id}", s.RemovePushMirror)
+						r.With(RepoPermissionMiddleware(s, "repo:owner")).Post("/rename", s.RenameRepo)
+						r.With(RepoPermissionMiddleware(s, "repo:owner")).Put("/transfer", s.TransferRepo)
+						r.With(RepoPermissionMiddleware(s, "repo:owner")).Delete("/transfer", s.TransferRepo)
+						r.With(RepoPermissionMiddleware(s, "repo:owner")).Post("/move", s.MoveRepo)
+					})
+					r.With(RepoPermissionMiddleware(s, "repo:delete")).Delete("/", s.DeleteRepo)
 				})
 			})
 		})
@@ -936,7 +1134,11 @@ func (s *State) StandardRouter() http.Handler {
 			r.Get("/", s.NewRepo)
 			r.Post("/", s.NewRepo)
 		})
-		// r.Post("/import", s.ImportRepo)
+		r.Route("/import", func(r chi.Router) {
+			r.Use(AuthMiddleware(s))
+			r.Get("/", s.ImportRepo)
+			r.Post("/", s.ImportRepo)
+		})
 	})
 
 	r.With(AuthMiddleware(s)).Route("/follow", func(r chi.Router) {
@@ -953,8 +1155,15 @@ func (s *State) StandardRouter() http.Handler {
 		r.Use(AuthMiddleware(s))
 		r.Get("/", s.Settings)
 		r.Put("/keys", s.SettingsKeys)
+		r.Put("/app-passwords", s.SettingsAppPasswords)
+		r.Delete("/app-passwords", s.SettingsAppPasswords)
 	})
 
+	r.Post("/app-passwords/verify", s.VerifyAppPassword)
+
+	// events reported by knots
+	r.With(KnotSignatureMiddleware(s)).Post("/knot-events/{domain}/push", s.KnotPush)
+
 	r.Get("/keys/{user}", s.Keys)
 
 	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
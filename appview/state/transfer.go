@@ -0,0 +1,379 @@
+package state
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	lexutil "github.com/bluesky-social/indigo/lex/util"
+	"github.com/sotangled/tangled/api/tangled"
+	"github.com/sotangled/tangled/appview/db"
+	"github.com/sotangled/tangled/appview/pages"
+)
+
+func (s *State) RenameRepo(w http.ResponseWriter, r *http.Request) {
+	user := s.auth.GetUser(r)
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	newName := strings.TrimSpace(r.FormValue("name"))
+	if newName == "" || newName == f.RepoName || strings.ContainsAny(newName, "/\\") || strings.HasPrefix(newName, ".") {
+		s.pages.Notice(w, "rename", "Invalid repo name.")
+		return
+	}
+
+	if existing, err := db.GetRepo(s.db, f.OwnerDid(), newName); err == nil && existing != nil {
+		s.pages.Notice(w, "rename", "You already have a repo by that name.")
+		return
+	}
+
+	secret, err := db.GetRegistrationKey(s.db, f.Knot)
+	if err != nil {
+		log.Printf("no key found for domain %s: %s\n", f.Knot, err)
+		s.pages.Notice(w, "rename", "Failed to rename repository.")
+		return
+	}
+
+	ksClient, err := NewSignedClient(f.Knot, secret, s.config.Dev)
+	if err != nil {
+		log.Println("failed to create client to ", f.Knot)
+		s.pages.Notice(w, "rename", "Failed to rename repository.")
+		return
+	}
+
+	resp, err := ksClient.RenameRepo(f.OwnerDid(), f.RepoName, f.OwnerDid(), newName)
+	if err != nil {
+		log.Println("failed to rename repo on knot", err)
+		s.pages.Notice(w, "rename", "Failed to rename repository on knot.")
+		return
+	}
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+	case http.StatusConflict:
+		s.pages.Notice(w, "rename", "A repository with that name already exists on the knot.")
+		return
+	default:
+		log.Println("failed to rename repo on knot", resp.Status)
+		s.pages.Notice(w, "rename", "Failed to rename repository on knot.")
+		return
+	}
+
+	// until the rename is committed here, put the repo back on any failure,
+	// or the appview would point at a path that no longer exists
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		resp, err := ksClient.RenameRepo(f.OwnerDid(), newName, f.OwnerDid(), f.RepoName)
+		if err != nil || resp.StatusCode != http.StatusNoContent {
+			log.Printf("failed to undo rename of %s on %s: %v", f.OwnerSlashRepo(), f.Knot, err)
+		}
+	}()
+
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Println("failed to start tx")
+		s.pages.Notice(w, "rename", "Failed to rename repository.")
+		return
+	}
+	defer func() {
+		tx.Rollback()
+		err = s.enforcer.E.LoadPolicy()
+		if err != nil {
+			log.Println("failed to rollback policies")
+		}
+	}()
+
+	err = db.RenameRepo(tx, f.RepoAt.String(), newName)
+	if err != nil {
+		log.Println("failed to rename repo", err)
+		s.pages.Notice(w, "rename", "Failed to rename repository.")
+		return
+	}
+
+	err = s.enforcer.RenameRepo(f.Knot, f.OwnerSlashRepo(), path.Join(f.OwnerDid(), newName))
+	if err != nil {
+		log.Println("failed to rename repo policies", err)
+		s.pages.Notice(w, "rename", "Failed to rename repository.")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Println("failed to commit changes", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	committed = true
+
+	err = s.enforcer.E.SavePolicy()
+	if err != nil {
+		log.Println("failed to update ACLs", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rkey := f.RepoAt.RecordKey().String()
+	client, _ := s.auth.AuthorizedClient(r)
+	ex, err := comatproto.RepoGetRecord(r.Context(), client, "", tangled.RepoNSID, user.Did, rkey)
+	if err != nil {
+		log.Println("failed to get repo record", err)
+	} else if record, ok := ex.Value.Val.(*tangled.Repo); ok {
+		record.Name = newName
+		_, err = comatproto.RepoPutRecord(r.Context(), client, &comatproto.RepoPutRecord_Input{
+			Collection: tangled.RepoNSID,
+			Repo:       user.Did,
+			Rkey:       rkey,
+			SwapRecord: ex.Cid,
+			Record:     &lexutil.LexiconTypeDecoder{Val: record},
+		})
+		if err != nil {
+			log.Println("failed to update repo record", err)
+		}
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s/%s/settings", f.OwnerDid(), newName))
+}
+
+// TransferRepo offers the repo to another user. It only changes hands once
+// they accept, as the repo record has to be published from their PDS.
+func (s *State) TransferRepo(w http.ResponseWriter, r *http.Request) {
+	user := s.auth.GetUser(r)
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		to := strings.TrimPrefix(strings.TrimSpace(r.FormValue("owner")), "@")
+		toIdent, err := s.resolver.ResolveIdent(r.Context(), to)
+		if err != nil {
+			s.pages.Notice(w, "transfer", "Could not find that user.")
+			return
+		}
+		if toIdent.DID.String() == f.OwnerDid() {
+			s.pages.Notice(w, "transfer", "You already own this repository.")
+			return
+		}
+
+		err = db.AddRepoTransfer(s.db, f.RepoAt.String(), user.Did, toIdent.DID.String())
+		if err != nil {
+			log.Println("failed to add transfer", err)
+			s.pages.Notice(w, "transfer", "Failed to start transfer.")
+			return
+		}
+
+	case http.MethodDelete:
+		err := db.RemoveRepoTransfer(s.db, f.RepoAt.String())
+		if err != nil {
+			log.Println("failed to remove transfer", err)
+			s.pages.Notice(w, "transfer", "Failed to cancel transfer.")
+			return
+		}
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s/settings", f.OwnerSlashRepo()))
+}
+
+// AcceptRepoTransfer shows a pending transfer to its recipient, and moves
+// the repo over to them once they accept it.
+func (s *State) AcceptRepoTransfer(w http.ResponseWriter, r *http.Request) {
+	user := s.auth.GetUser(r)
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	transfer, err := db.GetRepoTransfer(s.db, f.RepoAt.String())
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && transfer.ToDid != user.Did) {
+		w.WriteHeader(http.StatusNotFound)
+		s.pages.Error404(w)
+		return
+	}
+	if err != nil {
+		log.Println("failed to get transfer", err)
+		s.pages.Error503(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.pages.RepoTransfer(w, pages.RepoTransferParams{
+			LoggedInUser: user,
+			RepoInfo:     f.RepoInfo(s, user),
+			Transfer:     *transfer,
+		})
+
+	case http.MethodPost:
+		if existing, err := db.GetRepo(s.db, user.Did, f.RepoName); err == nil && existing != nil {
+			s.pages.Notice(w, "transfer", "You already have a repo by this name. Rename one of them first.")
+			return
+		}
+
+		secret, err := db.GetRegistrationKey(s.db, f.Knot)
+		if err != nil {
+			log.Printf("no key found for domain %s: %s\n", f.Knot, err)
+			s.pages.Notice(w, "transfer", "Failed to transfer repository.")
+			return
+		}
+
+		ksClient, err := NewSignedClient(f.Knot, secret, s.config.Dev)
+		if err != nil {
+			log.Println("failed to create client to ", f.Knot)
+			s.pages.Notice(w, "transfer", "Failed to transfer repository.")
+			return
+		}
+
+		// the old owner's record can only be deleted by them; once the
+		// repo is moved it no longer resolves to anything here
+		record := &tangled.Repo{
+			Knot:    f.Knot,
+			Name:    f.RepoName,
+			Owner:   user.Did,
+			AddedAt: &f.AddedAt,
+		}
+		if f.Description != "" {
+			record.Description = &f.Description
+		}
+		if f.Source != "" {
+			record.Source = &f.Source
+		}
+
+		rkey := s.TID()
+		client, _ := s.auth.AuthorizedClient(r)
+		atresp, err := comatproto.RepoPutRecord(r.Context(), client, &comatproto.RepoPutRecord_Input{
+			Collection: tangled.RepoNSID,
+			Repo:       user.Did,
+			Rkey:       rkey,
+			Record:     &lexutil.LexiconTypeDecoder{Val: record},
+		})
+		if err != nil {
+			log.Printf("failed to create record: %s", err)
+			s.pages.Notice(w, "transfer", "Failed to announce repository transfer.")
+			return
+		}
+
+		// until the transfer is committed here, undo whatever has been done
+		// on any failure, so the repo stays reachable under its old owner
+		moved, committed := false, false
+		defer func() {
+			if committed {
+				return
+			}
+			if moved {
+				resp, err := ksClient.RenameRepo(user.Did, f.RepoName, f.OwnerDid(), f.RepoName)
+				if err != nil || resp.StatusCode != http.StatusNoContent {
+					log.Printf("failed to undo move of %s on %s: %v", f.OwnerSlashRepo(), f.Knot, err)
+				}
+			}
+			_, err := comatproto.RepoDeleteRecord(r.Context(), client, &comatproto.RepoDeleteRecord_Input{
+				Collection: tangled.RepoNSID,
+				Repo:       user.Did,
+				Rkey:       rkey,
+			})
+			if err != nil {
+				log.Println("failed to delete repo record", err)
+			}
+		}()
+
+		resp, err := ksClient.RenameRepo(f.OwnerDid(), f.RepoName, user.Did, f.RepoName)
+		if err != nil || resp.StatusCode != http.StatusNoContent {
+			log.Printf("failed to move %s on %s: %v", f.OwnerSlashRepo(), f.Knot, err)
+			s.pages.Notice(w, "transfer", "Failed to transfer repository on knot.")
+			return
+		}
+		moved = true
+
+		tx, err := s.db.BeginTx(r.Context(), nil)
+		if err != nil {
+			log.Println("failed to start tx")
+			s.pages.Notice(w, "transfer", "Failed to transfer repository.")
+			return
+		}
+		defer func() {
+			tx.Rollback()
+			err = s.enforcer.E.LoadPolicy()
+			if err != nil {
+				log.Println("failed to rollback policies")
+			}
+		}()
+
+		err = db.RemoveRepoTransfer(tx, f.RepoAt.String())
+		if err == nil {
+			err = db.TransferRepo(tx, f.RepoAt.String(), user.Did, f.RepoName, rkey, atresp.Uri)
+		}
+		if err != nil {
+			log.Println("failed to transfer repo", err)
+			s.pages.Notice(w, "transfer", "Failed to transfer repository.")
+			return
+		}
+
+		err = s.enforcer.RenameRepo(f.Knot, f.OwnerSlashRepo(), path.Join(user.Did, f.RepoName))
+		if err != nil {
+			log.Println("failed to move repo policies", err)
+			s.pages.Notice(w, "transfer", "Failed to transfer repository.")
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Println("failed to commit changes", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		committed = true
+
+		err = s.enforcer.E.SavePolicy()
+		if err != nil {
+			log.Println("failed to update ACLs", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		s.pages.HxLocation(w, fmt.Sprintf("/%s/%s", user.Did, f.RepoName))
+	}
+}
+
+// redirectRenamedRepo sends requests for a repo's old owner/name on to where
+// it lives now. It reports whether it did.
+func (s *State) redirectRenamedRepo(w http.ResponseWriter, r *http.Request, did, name string) bool {
+	repoAt, err := db.GetRepoRedirect(s.db, did, name)
+	if err != nil {
+		return false
+	}
+
+	fullName := s.repoFullName(repoAt)
+	if fullName == "" {
+		return false
+	}
+
+	// keep everything after /{user}/{repo}
+	target := "/" + fullName
+	if parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3); len(parts) == 3 {
+		target += "/" + parts[2]
+	}
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+
+	// git pushes and fetches POST to the repo, and must not turn into GETs
+	status := http.StatusMovedPermanently
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		status = http.StatusPermanentRedirect
+	}
+
+	http.Redirect(w, r, target, status)
+	return true
+}
@@ -0,0 +1,329 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/go-chi/chi/v5"
+	"github.com/sotangled/tangled/appview/db"
+)
+
+const (
+	webhookPollInterval = 10 * time.Second
+	webhookMaxAttempts  = 6
+	webhookBaseBackoff  = 30 * time.Second
+)
+
+type WebhookRepo struct {
+	Did   string `json:"did"`
+	Name  string `json:"name"`
+	Knot  string `json:"knot"`
+	AtUri string `json:"atUri"`
+}
+
+// WebhookPayload is the JSON body of every webhook delivery. Data depends
+// on the event.
+type WebhookPayload struct {
+	Event     db.WebhookEvent `json:"event"`
+	Repo      WebhookRepo     `json:"repo"`
+	Sender    string          `json:"sender"`
+	Timestamp string          `json:"timestamp"`
+	Data      any             `json:"data"`
+}
+
+// queueWebhooks records a delivery of event for every webhook on the repo.
+// Failures are logged, they never fail the request that caused the event.
+func (s *State) queueWebhooks(repo WebhookRepo, event db.WebhookEvent, sender string, data any) {
+	payload, err := json.Marshal(WebhookPayload{
+		Event:     event,
+		Repo:      repo,
+		Sender:    sender,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Data:      data,
+	})
+	if err != nil {
+		log.Println("failed to marshal webhook payload", err)
+		return
+	}
+
+	err = db.EnqueueWebhookDeliveries(s.db, syntax.ATURI(repo.AtUri), event, payload)
+	if err != nil {
+		log.Println("failed to queue webhook deliveries", err)
+	}
+}
+
+func (f *FullyResolvedRepo) webhookRepo() WebhookRepo {
+	return WebhookRepo{
+		Did:   f.OwnerDid(),
+		Name:  f.RepoName,
+		Knot:  f.Knot,
+		AtUri: f.RepoAt.String(),
+	}
+}
+
+// webhookSignature signs a delivery the same way SignerTransport signs knot
+// requests, but over the body so receivers can verify the payload.
+func webhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookDialer refuses to connect to loopback, private and link-local
+// addresses, so webhooks can't be used to probe the appview's network. The
+// check runs on the resolved address, which also covers redirects and names
+// that resolve to such addresses.
+var webhookDialer = &net.Dialer{
+	Timeout: 10 * time.Second,
+	Control: func(network, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+			return fmt.Errorf("webhook address %s is not allowed", host)
+		}
+		return nil
+	},
+}
+
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified() &&
+		!cgnat.Contains(ip)
+}
+
+// validateWebhookUrl checks that raw is an absolute http(s) url, and that
+// its host is not an address deliveries would refuse anyway.
+func validateWebhookUrl(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("only http and https urls are supported")
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("missing host")
+	}
+	if u.User != nil {
+		return fmt.Errorf("credentials in the url are not supported, use the secret")
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !isPublicIP(ip) {
+		return fmt.Errorf("private and loopback addresses are not allowed")
+	}
+	return nil
+}
+
+// deliverWebhooks polls the delivery queue until ctx is done.
+func (s *State) deliverWebhooks(ctx context.Context) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// local receivers are handy in development
+	if !s.config.Dev {
+		transport.Proxy = nil
+		transport.DialContext = webhookDialer.DialContext
+	}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: transport}
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deliveries, err := db.GetDueWebhookDeliveries(s.db, 50)
+		if err != nil {
+			log.Println("failed to get webhook deliveries", err)
+			continue
+		}
+
+		for _, d := range deliveries {
+			s.attemptWebhookDelivery(ctx, client, &d)
+		}
+	}
+}
+
+func (s *State) attemptWebhookDelivery(ctx context.Context, client *http.Client, d *db.WebhookDelivery) {
+	d.Attempts++
+	d.ResponseCode = 0
+	d.Error = ""
+
+	err := func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Url, bytes.NewReader([]byte(d.Payload)))
+		if err != nil {
+			return err
+		}
+
+		timestamp := time.Now().Format(time.RFC3339)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "tangled-webhooks")
+		req.Header.Set("X-Tangled-Event", string(d.Event))
+		req.Header.Set("X-Tangled-Delivery", strconv.Itoa(d.Id))
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Signature", webhookSignature(d.Secret, timestamp, []byte(d.Payload)))
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		d.ResponseCode = resp.StatusCode
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("unexpected status: %s", resp.Status)
+		}
+		return nil
+	}()
+
+	switch {
+	case err == nil:
+		d.Status = db.DeliverySucceeded
+	case d.Attempts >= webhookMaxAttempts:
+		d.Status = db.DeliveryFailed
+		d.Error = err.Error()
+	default:
+		d.Error = err.Error()
+		d.NextAttempt = time.Now().Add(webhookBaseBackoff << (d.Attempts - 1))
+	}
+
+	if err := db.UpdateWebhookDelivery(s.db, d); err != nil {
+		log.Println("failed to update webhook delivery", err)
+	}
+}
+
+func (s *State) AddWebhook(w http.ResponseWriter, r *http.Request) {
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	hookUrl := strings.TrimSpace(r.FormValue("url"))
+	secret := r.FormValue("secret")
+	if hookUrl == "" || secret == "" {
+		s.pages.Notice(w, "webhooks", "URL and secret are required.")
+		return
+	}
+	if !s.config.Dev {
+		if err := validateWebhookUrl(hookUrl); err != nil {
+			s.pages.Notice(w, "webhooks", fmt.Sprintf("Invalid webhook URL: %s.", template.HTMLEscapeString(err.Error())))
+			return
+		}
+	}
+
+	var events []db.WebhookEvent
+	for _, e := range db.WebhookEvents {
+		if r.FormValue(string(e)) == "on" {
+			events = append(events, e)
+		}
+	}
+	if len(events) == 0 {
+		s.pages.Notice(w, "webhooks", "Select at least one event.")
+		return
+	}
+
+	err = db.AddWebhook(s.db, &db.Webhook{
+		RepoAt: f.RepoAt,
+		Url:    hookUrl,
+		Secret: secret,
+		Events: events,
+	})
+	if err != nil {
+		log.Println("failed to add webhook", err)
+		s.pages.Notice(w, "webhooks", "Failed to add webhook.")
+		return
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s/settings", f.OwnerSlashRepo()))
+}
+
+func (s *State) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	f, err := fullyResolvedRepo(r)
+	if err != nil {
+		log.Println("failed to get repo and knot", err)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "bad webhook id", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.DeleteWebhook(s.db, f.RepoAt, id); err != nil {
+		log.Println("failed to delete webhook", err)
+		s.pages.Notice(w, "webhooks", "Failed to delete webhook.")
+		return
+	}
+
+	s.pages.HxLocation(w, fmt.Sprintf("/%s/settings", f.OwnerSlashRepo()))
+}
+
+// KnotPush receives ref updates from a knot's post-receive hook and queues
+// push webhooks for them.
+func (s *State) KnotPush(w http.ResponseWriter, r *http.Request) {
+	domain := chi.URLParam(r, "domain")
+
+	var data struct {
+		Did     string `json:"did"`
+		Name    string `json:"name"`
+		Pusher  string `json:"pusher"`
+		Updates []struct {
+			OldSha string `json:"oldSha"`
+			NewSha string `json:"newSha"`
+			Ref    string `json:"ref"`
+		} `json:"updates"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	repo, err := db.GetRepo(s.db, data.Did, data.Name)
+	if err != nil || repo.Knot != domain {
+		http.Error(w, "unknown repository", http.StatusNotFound)
+		return
+	}
+
+	webhookRepo := WebhookRepo{
+		Did:   repo.Did,
+		Name:  repo.Name,
+		Knot:  repo.Knot,
+		AtUri: repo.AtUri,
+	}
+	for _, u := range data.Updates {
+		s.queueWebhooks(webhookRepo, db.WebhookPush, data.Pusher, map[string]string{
+			"ref":    u.Ref,
+			"before": u.OldSha,
+			"after":  u.NewSha,
+		})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
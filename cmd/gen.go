@@ -21,6 +21,9 @@ func main() {
 		shtangled.RepoIssueComment{},
 		shtangled.RepoIssueState{},
 		shtangled.RepoIssue{},
+		shtangled.RepoPull{},
+		shtangled.RepoPullComment{},
+		shtangled.RepoPullStatus{},
 		shtangled.Repo{},
 	); err != nil {
 		panic(err)
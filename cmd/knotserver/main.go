@@ -2,13 +2,17 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"net/http"
+	"os"
 
 	"github.com/sotangled/tangled/api/tangled"
 	"github.com/sotangled/tangled/jetstream"
 	"github.com/sotangled/tangled/knotserver"
 	"github.com/sotangled/tangled/knotserver/config"
 	"github.com/sotangled/tangled/knotserver/db"
+	"github.com/sotangled/tangled/knotserver/hook"
 	"github.com/sotangled/tangled/log"
 	"github.com/sotangled/tangled/rbac"
 
@@ -16,6 +20,11 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "hook" {
+		runHook(os.Args[2:])
+		return
+	}
+
 	ctx := context.Background()
 	// ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	// defer stop()
@@ -59,7 +68,7 @@ func main() {
 		l.Error("failed to setup server", "error", err)
 		return
 	}
-	imux := knotserver.Internal(ctx, db, e)
+	imux := knotserver.Internal(ctx, c, db, e, l)
 
 	l.Info("starting internal server", "address", c.Server.InternalListenAddr)
 	go http.ListenAndServe(c.Server.InternalListenAddr, imux)
@@ -69,3 +78,27 @@ func main() {
 
 	return
 }
+
+// runHook is invoked by the git hooks that the knot installs into each
+// repository.
+func runHook(args []string) {
+	fs := flag.NewFlagSet("hook", flag.ExitOnError)
+	internalAPI := fs.String("internal-api", "http://127.0.0.1:5444", "Internal API endpoint")
+	fs.Parse(args)
+
+	switch fs.Arg(0) {
+	case "pre-receive":
+		if err := hook.PreReceive(*internalAPI, os.Stdin); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "post-receive":
+		// a failing post-receive hook can't undo the push, so only warn
+		if err := hook.PostReceive(*internalAPI, os.Stdin); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to notify knot of push: %v\n", err)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown hook: %q\n", fs.Arg(0))
+		os.Exit(1)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -14,6 +15,8 @@ import (
 
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/sotangled/tangled/appview"
+	"github.com/sotangled/tangled/knotserver/hook"
+	"github.com/sotangled/tangled/types"
 )
 
 var (
@@ -90,7 +93,7 @@ func main() {
 		exitWithLog("access denied: invalid git command")
 	}
 
-	if gitCommand != "git-upload-pack" {
+	if gitCommand == "git-receive-pack" {
 		if !isPushPermitted(*incomingUser, qualifiedRepoName) {
 			logEvent("all infos", map[string]interface{}{
 				"did":      *incomingUser,
@@ -98,6 +101,13 @@ func main() {
 			})
 			exitWithLog("access denied: user not allowed")
 		}
+		if source, ok := mirrorSource(qualifiedRepoName); ok {
+			exitWithLog(fmt.Sprintf("access denied: this repository is a mirror of %s, push there instead", source))
+		}
+	} else {
+		if !isReadPermitted(*incomingUser, qualifiedRepoName) {
+			exitWithLog("access denied: repository not found")
+		}
 	}
 
 	fullPath, _ := securejoin.SecureJoin(*baseDirFlag, qualifiedRepoName)
@@ -117,6 +127,7 @@ func main() {
 	}
 
 	cmd := exec.Command(gitCommand, fullPath)
+	cmd.Env = append(os.Environ(), hook.PusherEnv+"="+*incomingUser)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
@@ -186,7 +197,40 @@ func cleanup() {
 }
 
 func isPushPermitted(user, qualifiedRepoName string) bool {
-	u, _ := url.Parse(*endpoint + "/push-allowed")
+	return checkPermission("/push-allowed", user, qualifiedRepoName)
+}
+
+func isReadPermitted(user, qualifiedRepoName string) bool {
+	return checkPermission("/read-allowed", user, qualifiedRepoName)
+}
+
+// mirrorSource returns the upstream of the repo, if it is a mirror.
+func mirrorSource(qualifiedRepoName string) (string, bool) {
+	u, _ := url.Parse(*endpoint + "/mirror")
+	q := u.Query()
+	q.Add("repo", qualifiedRepoName)
+	u.RawQuery = q.Encode()
+
+	resp, err := http.Get(u.String())
+	if err != nil {
+		exitWithLog(fmt.Sprintf("error checking for mirror: %v", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", false
+	}
+
+	var mirror types.Mirror
+	if err := json.NewDecoder(resp.Body).Decode(&mirror); err != nil {
+		exitWithLog(fmt.Sprintf("error checking for mirror: %v", err))
+	}
+
+	return mirror.Source, true
+}
+
+func checkPermission(path, user, qualifiedRepoName string) bool {
+	u, _ := url.Parse(*endpoint + path)
 	q := u.Query()
 	q.Add("user", user)
 	q.Add("repo", qualifiedRepoName)
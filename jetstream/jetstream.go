@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
 	"time"
 
@@ -58,6 +59,21 @@ func (j *JetstreamClient) UpdateDids(dids []string) {
 	j.cancelMu.Unlock()
 }
 
+// RemoveDid stops listening for events from did.
+func (j *JetstreamClient) RemoveDid(did string) {
+	j.mu.Lock()
+	j.cfg.WantedDids = slices.DeleteFunc(j.cfg.WantedDids, func(d string) bool {
+		return d == did
+	})
+	j.mu.Unlock()
+
+	j.cancelMu.Lock()
+	if j.cancel != nil {
+		j.cancel()
+	}
+	j.cancelMu.Unlock()
+}
+
 func NewJetstreamClient(ident string, collections []string, cfg *client.ClientConfig, logger *slog.Logger, db DB, waitForDid bool) (*JetstreamClient, error) {
 	if cfg == nil {
 		cfg = client.DefaultClientConfig()
@@ -0,0 +1,99 @@
+package knotserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"path/filepath"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/go-chi/chi/v5"
+	"github.com/sotangled/tangled/knotserver/hook"
+	"github.com/sotangled/tangled/types"
+)
+
+// checkBranchRules returns an error naming the first rule that u violates.
+func checkBranchRules(rules []types.BranchRule, u hook.RefUpdate, isOwner bool) error {
+	for _, rule := range rules {
+		if !rule.Matches(u.Ref) {
+			continue
+		}
+
+		switch {
+		case rule.NoDeletion && u.IsDeletion():
+			return fmt.Errorf("branch protection rule %q: deleting %s is not allowed", rule.Pattern, u.Ref)
+		case rule.NoForcePush && u.Forced:
+			return fmt.Errorf("branch protection rule %q: force pushing to %s is not allowed", rule.Pattern, u.Ref)
+		case rule.OwnersOnly && !isOwner:
+			return fmt.Errorf("branch protection rule %q: only repository owners may push to %s", rule.Pattern, u.Ref)
+		}
+	}
+
+	return nil
+}
+
+func (h *Handle) BranchRules(w http.ResponseWriter, r *http.Request) {
+	repo, _ := securejoin.SecureJoin(chi.URLParam(r, "did"), chi.URLParam(r, "name"))
+
+	rules, err := h.db.GetBranchRules(repo)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, types.BranchRulesResponse{Rules: rules})
+}
+
+func (h *Handle) AddBranchRule(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "AddBranchRule")
+
+	data := struct {
+		Did  string           `json:"did"`
+		Name string           `json:"name"`
+		Rule types.BranchRule `json:"rule"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := path.Match(data.Rule.Pattern, ""); err != nil || data.Rule.Pattern == "" {
+		writeError(w, "invalid branch pattern", http.StatusBadRequest)
+		return
+	}
+
+	repo := filepath.Join(data.Did, data.Name)
+	if err := h.db.AddBranchRule(repo, data.Rule); err != nil {
+		l.Error("adding branch rule", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handle) RemoveBranchRule(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "RemoveBranchRule")
+
+	data := struct {
+		Did  string `json:"did"`
+		Name string `json:"name"`
+		Id   int64  `json:"id"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	repo := filepath.Join(data.Did, data.Name)
+	if err := h.db.RemoveBranchRule(repo, data.Id); err != nil {
+		l.Error("removing branch rule", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
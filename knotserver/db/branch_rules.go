@@ -0,0 +1,46 @@
+package db
+
+import "github.com/sotangled/tangled/types"
+
+func (d *DB) AddBranchRule(repo string, rule types.BranchRule) error {
+	query := `insert into branch_rules (repo, pattern, no_force_push, no_deletion, owners_only)
+		values (?, ?, ?, ?, ?)
+		on conflict(repo, pattern) do update set
+			no_force_push = excluded.no_force_push,
+			no_deletion = excluded.no_deletion,
+			owners_only = excluded.owners_only`
+	_, err := d.db.Exec(query, repo, rule.Pattern, rule.NoForcePush, rule.NoDeletion, rule.OwnersOnly)
+	return err
+}
+
+func (d *DB) RemoveBranchRule(repo string, id int64) error {
+	_, err := d.db.Exec(`delete from branch_rules where repo = ? and id = ?`, repo, id)
+	return err
+}
+
+func (d *DB) GetBranchRules(repo string) ([]types.BranchRule, error) {
+	var rules []types.BranchRule
+
+	rows, err := d.db.Query(
+		`select id, pattern, no_force_push, no_deletion, owners_only from branch_rules where repo = ? order by pattern`,
+		repo,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rule types.BranchRule
+		if err := rows.Scan(&rule.Id, &rule.Pattern, &rule.NoForcePush, &rule.NoDeletion, &rule.OwnersOnly); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
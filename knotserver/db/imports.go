@@ -0,0 +1,54 @@
+package db
+
+import "github.com/sotangled/tangled/types"
+
+func (d *DB) StartImport(repo, source string) error {
+	query := `insert into imports (repo, source, status) values (?, ?, ?)
+		on conflict(repo) do update set
+			source = excluded.source,
+			status = excluded.status,
+			progress = '',
+			error = '',
+			updated = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')`
+	_, err := d.db.Exec(query, repo, source, types.ImportRunning)
+	return err
+}
+
+func (d *DB) UpdateImportProgress(repo, progress string) error {
+	_, err := d.db.Exec(
+		`update imports set progress = ?, updated = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') where repo = ?`,
+		progress, repo,
+	)
+	return err
+}
+
+func (d *DB) FinishImport(repo string, status types.ImportStatus, importErr string) error {
+	_, err := d.db.Exec(
+		`update imports set status = ?, error = ?, updated = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') where repo = ?`,
+		status, importErr, repo,
+	)
+	return err
+}
+
+func (d *DB) GetImport(repo string) (*types.ImportResponse, error) {
+	var imp types.ImportResponse
+	err := d.db.QueryRow(
+		`select source, status, progress, error from imports where repo = ?`,
+		repo,
+	).Scan(&imp.Source, &imp.Status, &imp.Progress, &imp.Error)
+	if err != nil {
+		return nil, err
+	}
+
+	return &imp, nil
+}
+
+// InterruptImports fails imports that were still running when the knot
+// last stopped.
+func (d *DB) InterruptImports() error {
+	_, err := d.db.Exec(
+		`update imports set status = ?, error = ? where status = ?`,
+		types.ImportFailed, "interrupted by a knot restart", types.ImportRunning,
+	)
+	return err
+}
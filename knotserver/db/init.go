@@ -39,6 +39,57 @@ func Setup(dbPath string) (*DB, error) {
 			foreign key (did) references known_dids(did) on delete cascade
 		);
 
+		create table if not exists ref_updates (
+			id integer primary key autoincrement,
+			repo text not null,
+			pusher text not null,
+			ref text not null,
+			old_sha text not null,
+			new_sha text not null,
+			created text not null default (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+		);
+
+		create table if not exists imports (
+			repo text primary key,
+			source text not null,
+			status text not null,
+			progress text not null default '',
+			error text not null default '',
+			updated text not null default (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+		);
+
+		create table if not exists mirrors (
+			repo text primary key,
+			source text not null,
+			interval integer not null,
+			last_sync text,
+			last_error text not null default '',
+			created text not null default (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+		);
+
+		create table if not exists push_mirrors (
+			id integer primary key autoincrement,
+			repo text not null,
+			url text not null,
+			username text not null default '',
+			password text not null default '', -- sealed with the knot secret
+			last_push text,
+			last_error text not null default '',
+			created text not null default (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+			unique(repo, url)
+		);
+
+		create table if not exists branch_rules (
+			id integer primary key autoincrement,
+			repo text not null,
+			pattern text not null,
+			no_force_push integer not null default 0,
+			no_deletion integer not null default 0,
+			owners_only integer not null default 0,
+			created text not null default (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+			unique(repo, pattern)
+		);
+
 		create table if not exists _jetstream (
 			id integer primary key autoincrement,
 			last_time_us integer not null
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/sotangled/tangled/types"
+)
+
+func (d *DB) SetMirror(repo, source string, interval int) error {
+	query := `insert into mirrors (repo, source, interval) values (?, ?, ?)
+		on conflict(repo) do update set
+			source = excluded.source,
+			interval = excluded.interval,
+			last_sync = null,
+			last_error = ''`
+	_, err := d.db.Exec(query, repo, source, interval)
+	return err
+}
+
+func (d *DB) RemoveMirror(repo string) error {
+	_, err := d.db.Exec(`delete from mirrors where repo = ?`, repo)
+	return err
+}
+
+func (d *DB) UpdateMirrorSync(repo string, syncedAt time.Time, syncErr string) error {
+	_, err := d.db.Exec(
+		`update mirrors set last_sync = ?, last_error = ? where repo = ?`,
+		syncedAt.UTC().Format(time.RFC3339), syncErr, repo,
+	)
+	return err
+}
+
+func (d *DB) GetMirror(repo string) (*types.Mirror, error) {
+	row := d.db.QueryRow(`select source, interval, last_sync, last_error from mirrors where repo = ?`, repo)
+
+	var m types.Mirror
+	var lastSync sql.NullString
+	if err := row.Scan(&m.Source, &m.Interval, &lastSync, &m.LastError); err != nil {
+		return nil, err
+	}
+	m.LastSync = parseSyncTime(lastSync)
+
+	return &m, nil
+}
+
+// GetMirrors returns every mirror on the knot, keyed by repo.
+func (d *DB) GetMirrors() (map[string]types.Mirror, error) {
+	mirrors := make(map[string]types.Mirror)
+
+	rows, err := d.db.Query(`select repo, source, interval, last_sync, last_error from mirrors`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var repo string
+		var m types.Mirror
+		var lastSync sql.NullString
+		if err := rows.Scan(&repo, &m.Source, &m.Interval, &lastSync, &m.LastError); err != nil {
+			return nil, err
+		}
+		m.LastSync = parseSyncTime(lastSync)
+		mirrors[repo] = m
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return mirrors, nil
+}
+
+func parseSyncTime(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
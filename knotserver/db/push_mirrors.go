@@ -0,0 +1,67 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/sotangled/tangled/types"
+)
+
+// PushMirror is a push mirror along with its sealed password.
+type PushMirror struct {
+	types.PushMirror
+	Password string
+}
+
+func (d *DB) AddPushMirror(repo, url, username, password string) error {
+	query := `insert into push_mirrors (repo, url, username, password) values (?, ?, ?, ?)
+		on conflict(repo, url) do update set
+			username = excluded.username,
+			password = excluded.password,
+			last_push = null,
+			last_error = ''`
+	_, err := d.db.Exec(query, repo, url, username, password)
+	return err
+}
+
+func (d *DB) RemovePushMirror(repo string, id int64) error {
+	_, err := d.db.Exec(`delete from push_mirrors where repo = ? and id = ?`, repo, id)
+	return err
+}
+
+func (d *DB) UpdatePushMirrorStatus(id int64, pushedAt time.Time, pushErr string) error {
+	_, err := d.db.Exec(
+		`update push_mirrors set last_push = ?, last_error = ? where id = ?`,
+		pushedAt.UTC().Format(time.RFC3339), pushErr, id,
+	)
+	return err
+}
+
+func (d *DB) GetPushMirrors(repo string) ([]PushMirror, error) {
+	var mirrors []PushMirror
+
+	rows, err := d.db.Query(
+		`select id, url, username, password, last_push, last_error from push_mirrors where repo = ? order by id`,
+		repo,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m PushMirror
+		var lastPush sql.NullString
+		if err := rows.Scan(&m.Id, &m.Url, &m.Username, &m.Password, &lastPush, &m.LastError); err != nil {
+			return nil, err
+		}
+		m.LastPush = parseSyncTime(lastPush)
+		mirrors = append(mirrors, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return mirrors, nil
+}
@@ -0,0 +1,16 @@
+package db
+
+type RefUpdate struct {
+	Repo    string
+	Pusher  string
+	Ref     string
+	OldSha  string
+	NewSha  string
+	Created string
+}
+
+func (d *DB) AddRefUpdate(u RefUpdate) error {
+	query := `insert into ref_updates (repo, pusher, ref, old_sha, new_sha) values (?, ?, ?, ?, ?)`
+	_, err := d.db.Exec(query, u.Repo, u.Pusher, u.Ref, u.OldSha, u.NewSha)
+	return err
+}
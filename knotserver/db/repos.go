@@ -0,0 +1,39 @@
+package db
+
+// repoTables are the tables holding data about a repo, keyed by its
+// did/name path in the repo column.
+var repoTables = []string{"ref_updates", "imports", "mirrors", "push_mirrors", "branch_rules"}
+
+// RemoveRepo deletes everything the knot stores about repo.
+func (d *DB) RemoveRepo(repo string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range repoTables {
+		if _, err := tx.Exec(`delete from `+table+` where repo = ?`, repo); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RenameRepo moves everything the knot stores about oldRepo to newRepo.
+func (d *DB) RenameRepo(oldRepo, newRepo string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range repoTables {
+		if _, err := tx.Exec(`update `+table+` set repo = ? where repo = ?`, newRepo, oldRepo); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -9,9 +9,15 @@ import (
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/go-chi/chi/v5"
 	"github.com/sotangled/tangled/knotserver/git/service"
+	"github.com/sotangled/tangled/knotserver/hook"
 )
 
 func (d *Handle) InfoRefs(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("service") == "git-receive-pack" {
+		d.VerifyPush(http.HandlerFunc(d.receivePackInfoRefs)).ServeHTTP(w, r)
+		return
+	}
+
 	did := chi.URLParam(r, "did")
 	name := chi.URLParam(r, "name")
 	repo, _ := securejoin.SecureJoin(d.c.Repo.ScanPath, filepath.Join(did, name))
@@ -20,8 +26,9 @@ func (d *Handle) InfoRefs(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 
 	cmd := service.ServiceCommand{
-		Dir:    repo,
-		Stdout: w,
+		Dir:         repo,
+		Stdout:      w,
+		GitProtocol: r.Header.Get("Git-Protocol"),
 	}
 
 	if err := cmd.InfoRefs(); err != nil {
@@ -31,38 +38,98 @@ func (d *Handle) InfoRefs(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (d *Handle) receivePackInfoRefs(w http.ResponseWriter, r *http.Request) {
+	did := chi.URLParam(r, "did")
+	name := chi.URLParam(r, "name")
+	repo, _ := securejoin.SecureJoin(d.c.Repo.ScanPath, filepath.Join(did, name))
+
+	w.Header().Set("content-type", "application/x-git-receive-pack-advertisement")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	cmd := service.ServiceCommand{
+		Dir:         repo,
+		Stdout:      w,
+		GitProtocol: r.Header.Get("Git-Protocol"),
+	}
+
+	if err := cmd.ReceivePackInfoRefs(); err != nil {
+		http.Error(w, err.Error(), 500)
+		d.l.Error("git: failed to execute git-receive-pack (info/refs)", "handler", "InfoRefs", "error", err)
+		return
+	}
+}
+
 func (d *Handle) UploadPack(w http.ResponseWriter, r *http.Request) {
 	did := chi.URLParam(r, "did")
 	name := chi.URLParam(r, "name")
 	repo, _ := securejoin.SecureJoin(d.c.Repo.ScanPath, filepath.Join(did, name))
 
+	reader, err := requestBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		d.l.Error("git: failed to create gzip reader", "handler", "UploadPack", "error", err)
+		return
+	}
+	defer reader.Close()
+
 	w.Header().Set("content-type", "application/x-git-upload-pack-result")
 	w.Header().Set("Connection", "Keep-Alive")
 	w.Header().Set("Transfer-Encoding", "chunked")
 	w.WriteHeader(http.StatusOK)
 
 	cmd := service.ServiceCommand{
-		Dir:    repo,
-		Stdout: w,
+		Dir:         repo,
+		Stdin:       reader,
+		Stdout:      w,
+		GitProtocol: r.Header.Get("Git-Protocol"),
 	}
 
-	var reader io.ReadCloser
-	reader = r.Body
+	if err := cmd.UploadPack(); err != nil {
+		http.Error(w, err.Error(), 500)
+		d.l.Error("git: failed to execute git-upload-pack", "handler", "UploadPack", "error", err)
+		return
+	}
+}
 
-	if r.Header.Get("Content-Encoding") == "gzip" {
-		reader, err := gzip.NewReader(r.Body)
-		if err != nil {
-			http.Error(w, err.Error(), 500)
-			d.l.Error("git: failed to create gzip reader", "handler", "UploadPack", "error", err)
-			return
-		}
-		defer reader.Close()
+func (d *Handle) ReceivePack(w http.ResponseWriter, r *http.Request) {
+	did := chi.URLParam(r, "did")
+	name := chi.URLParam(r, "name")
+	repo, _ := securejoin.SecureJoin(d.c.Repo.ScanPath, filepath.Join(did, name))
+
+	reader, err := requestBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		d.l.Error("git: failed to create gzip reader", "handler", "ReceivePack", "error", err)
+		return
 	}
+	defer reader.Close()
 
-	cmd.Stdin = reader
-	if err := cmd.UploadPack(); err != nil {
+	w.Header().Set("content-type", "application/x-git-receive-pack-result")
+	w.Header().Set("Connection", "Keep-Alive")
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.WriteHeader(http.StatusOK)
+
+	pusher, _ := r.Context().Value("pusher").(string)
+	cmd := service.ServiceCommand{
+		Dir:    repo,
+		Env:    []string{hook.PusherEnv + "=" + pusher},
+		Stdin:  reader,
+		Stdout: w,
+	}
+
+	if err := cmd.ReceivePack(); err != nil {
 		http.Error(w, err.Error(), 500)
-		d.l.Error("git: failed to execute git-upload-pack", "handler", "UploadPack", "error", err)
+		d.l.Error("git: failed to execute git-receive-pack", "handler", "ReceivePack", "error", err)
 		return
 	}
 }
+
+// requestBody returns the request body, transparently decompressing it if
+// the client sent it gzipped.
+func requestBody(r *http.Request) (io.ReadCloser, error) {
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		return gzip.NewReader(r.Body)
+	}
+	return r.Body, nil
+}
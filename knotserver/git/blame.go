@@ -0,0 +1,100 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sotangled/tangled/types"
+)
+
+// Blame attributes every line of the file at path to the commit that last
+// changed it, as of g.h.
+func (g *GitRepo) Blame(path string) ([]types.BlameLine, error) {
+	cmd := exec.Command("git", "-C", g.path, "blame", "--porcelain", g.h.String(), "--", path)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git blame: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseBlame(out)
+}
+
+// parseBlame reads git-blame's porcelain format. Commit details are only
+// printed the first time a commit shows up, so they are remembered by hash.
+func parseBlame(out []byte) ([]types.BlameLine, error) {
+	commits := make(map[string]*types.BlameLine)
+	lines := []types.BlameLine{}
+
+	var current *types.BlameLine
+	var lineNo int
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if content, ok := strings.CutPrefix(line, "\t"); ok {
+			if current == nil {
+				return nil, fmt.Errorf("malformed blame output")
+			}
+			l := *current
+			l.LineNo = lineNo
+			l.Content = content
+			lines = append(lines, l)
+			current = nil
+			continue
+		}
+
+		if current == nil {
+			// header: <hash> <orig line> <final line> [<lines in group>]
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("malformed blame header: %q", line)
+			}
+			hash := fields[0]
+			n, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("malformed blame header: %q", line)
+			}
+			lineNo = n
+
+			c, ok := commits[hash]
+			if !ok {
+				c = &types.BlameLine{Hash: hash}
+				commits[hash] = c
+			}
+			current = c
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "author":
+			current.AuthorName = value
+		case "author-mail":
+			current.AuthorEmail = strings.Trim(value, "<>")
+		case "author-time":
+			sec, err := strconv.ParseInt(value, 10, 64)
+			if err == nil {
+				current.AuthorTime = time.Unix(sec, 0)
+			}
+		case "summary":
+			current.Summary = value
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
@@ -0,0 +1,100 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// BlobReader gives seekable access to a blob without holding it in memory.
+// Seeking just records the offset; the next Read reopens the object and
+// skips ahead to it.
+type BlobReader struct {
+	blob   *object.Blob
+	offset int64
+	rc     io.ReadCloser
+}
+
+func (g *GitRepo) RawFile(path string) (*BlobReader, error) {
+	c, err := g.r.CommitObject(g.h)
+	if err != nil {
+		return nil, fmt.Errorf("commit object: %w", err)
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("file tree: %w", err)
+	}
+
+	file, err := tree.File(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BlobReader{blob: &file.Blob}, nil
+}
+
+func (b *BlobReader) Hash() string {
+	return b.blob.Hash.String()
+}
+
+func (b *BlobReader) Size() int64 {
+	return b.blob.Size
+}
+
+func (b *BlobReader) Read(p []byte) (int, error) {
+	if b.rc == nil {
+		rc, err := b.blob.Reader()
+		if err != nil {
+			return 0, err
+		}
+		if _, err := io.CopyN(io.Discard, rc, b.offset); err != nil {
+			rc.Close()
+			if errors.Is(err, io.EOF) {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		b.rc = rc
+	}
+
+	n, err := b.rc.Read(p)
+	b.offset += int64(n)
+	return n, err
+}
+
+func (b *BlobReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = b.offset + offset
+	case io.SeekEnd:
+		abs = b.blob.Size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+	if abs < 0 {
+		return 0, fmt.Errorf("negative position: %d", abs)
+	}
+
+	if abs != b.offset && b.rc != nil {
+		b.rc.Close()
+		b.rc = nil
+	}
+	b.offset = abs
+
+	return abs, nil
+}
+
+func (b *BlobReader) Close() error {
+	if b.rc == nil {
+		return nil
+	}
+	err := b.rc.Close()
+	b.rc = nil
+	return err
+}
@@ -0,0 +1,80 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+)
+
+// HasRefs reports whether the repo at path has any branches or tags.
+func HasRefs(path string) (bool, error) {
+	out, err := exec.Command("git", "-C", path, "for-each-ref", "--count=1").Output()
+	if err != nil {
+		return false, err
+	}
+	return len(bytes.TrimSpace(out)) > 0, nil
+}
+
+// Bundle writes a bundle of every ref in the repo at path to w. The repo
+// must have at least one ref.
+func Bundle(path string, w io.Writer) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "-C", path, "bundle", "create", "-", "--all")
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("creating bundle: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Unbundle creates a bare repo at path from the bundle read from r.
+func Unbundle(path string, r io.Reader, hooks HookConfig) error {
+	if _, err := os.Stat(path); err == nil {
+		return gogit.ErrRepositoryAlreadyExists
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating user directory: %w", err)
+	}
+
+	f, err := os.CreateTemp("", "knot-*.bundle")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("receiving bundle: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "clone", "--bare", "--", f.Name(), path)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.RemoveAll(path)
+		return fmt.Errorf("cloning bundle: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// the bundle is gone once we're done
+	if err := exec.Command("git", "-C", path, "remote", "remove", "origin").Run(); err != nil {
+		os.RemoveAll(path)
+		return fmt.Errorf("removing bundle remote: %w", err)
+	}
+
+	if err := InstallHooks(path, hooks); err != nil {
+		return fmt.Errorf("installing hooks: %w", err)
+	}
+
+	return nil
+}
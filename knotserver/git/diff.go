@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/sotangled/tangled/types"
 )
@@ -40,12 +41,7 @@ func (g *GitRepo) Diff() (*types.NiceDiff, error) {
 		}
 	}
 
-	diffs, _, err := gitdiff.Parse(strings.NewReader(patch.String()))
-	if err != nil {
-		log.Println(err)
-	}
-
-	nd := types.NiceDiff{}
+	nd := niceDiff(patch)
 	nd.Commit.This = c.Hash.String()
 
 	if parent.Hash.IsZero() {
@@ -56,6 +52,85 @@ func (g *GitRepo) Diff() (*types.NiceDiff, error) {
 	nd.Commit.Author = c.Author
 	nd.Commit.Message = c.Message
 
+	return nd, nil
+}
+
+// Compare describes what head (the ref g was opened at) adds on top of
+// base, the same way `git diff base...head` and `git log base..head` do.
+func (g *GitRepo) Compare(base string) (*types.RepoCompareResponse, error) {
+	baseHash, err := g.r.ResolveRevision(plumbing.Revision(base))
+	if err != nil {
+		return nil, fmt.Errorf("resolving rev %s: %w", base, err)
+	}
+
+	baseCommit, err := g.r.CommitObject(*baseHash)
+	if err != nil {
+		return nil, fmt.Errorf("base commit: %w", err)
+	}
+
+	headCommit, err := g.r.CommitObject(g.h)
+	if err != nil {
+		return nil, fmt.Errorf("head commit: %w", err)
+	}
+
+	bases, err := baseCommit.MergeBase(headCommit)
+	if err != nil {
+		return nil, fmt.Errorf("merge base: %w", err)
+	}
+	if len(bases) == 0 {
+		return nil, ErrNoMergeBase
+	}
+	mergeBase := bases[0]
+
+	// rev-list handles merges in the range correctly, which a plain walk
+	// from head stopping at the merge-base would not.
+	out, err := g.runGit(nil, nil, "rev-list", fmt.Sprintf("%s..%s", baseHash, g.h))
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []*object.Commit{}
+	for _, h := range strings.Fields(out) {
+		c, err := g.r.CommitObject(plumbing.NewHash(h))
+		if err != nil {
+			return nil, fmt.Errorf("commit object: %w", err)
+		}
+		commits = append(commits, c)
+	}
+
+	mergeBaseTree, err := mergeBase.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("merge base tree: %w", err)
+	}
+
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("head tree: %w", err)
+	}
+
+	patch, err := mergeBaseTree.Patch(headTree)
+	if err != nil {
+		return nil, fmt.Errorf("patch: %w", err)
+	}
+
+	nd := niceDiff(patch)
+	nd.Commit.This = g.h.String()
+	nd.Commit.Parent = mergeBase.Hash.String()
+
+	return &types.RepoCompareResponse{
+		MergeBase: mergeBase.Hash.String(),
+		Commits:   commits,
+		Diff:      nd,
+	}, nil
+}
+
+func niceDiff(patch *object.Patch) *types.NiceDiff {
+	diffs, _, err := gitdiff.Parse(strings.NewReader(patch.String()))
+	if err != nil {
+		log.Println(err)
+	}
+
+	nd := types.NiceDiff{}
 	for _, d := range diffs {
 		ndiff := types.Diff{}
 		ndiff.Name.New = d.NewName
@@ -83,5 +158,5 @@ func (g *GitRepo) Diff() (*types.NiceDiff, error) {
 
 	nd.Stat.FilesChanged = len(diffs)
 
-	return &nd, nil
+	return &nd
 }
@@ -2,6 +2,7 @@ package git
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bytes"
 	"fmt"
 	"io"
@@ -18,6 +19,7 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/sotangled/tangled/types"
 )
 
@@ -37,7 +39,8 @@ func init() {
 }
 
 var (
-	ErrBinaryFile = fmt.Errorf("binary file")
+	ErrBinaryFile  = fmt.Errorf("binary file")
+	ErrNoMergeBase = fmt.Errorf("no merge base")
 )
 
 type GitRepo struct {
@@ -131,21 +134,67 @@ func Open(path string, ref string) (*GitRepo, error) {
 	return &g, nil
 }
 
-func (g *GitRepo) Commits() ([]*object.Commit, error) {
+// Commits walks history from g.h, skipping the first offset commits and
+// returning at most limit. The walk stops as soon as the page is filled.
+func (g *GitRepo) Commits(offset, limit int) ([]*object.Commit, error) {
 	ci, err := g.r.Log(&git.LogOptions{From: g.h})
 	if err != nil {
 		return nil, fmt.Errorf("commits from ref: %w", err)
 	}
+	defer ci.Close()
 
 	commits := []*object.Commit{}
-	ci.ForEach(func(c *object.Commit) error {
-		commits = append(commits, c)
+	idx := 0
+	err = ci.ForEach(func(c *object.Commit) error {
+		if idx >= offset+limit {
+			return storer.ErrStop
+		}
+		if idx >= offset {
+			commits = append(commits, c)
+		}
+		idx++
 		return nil
 	})
+	if err != nil {
+		return nil, fmt.Errorf("walking commits: %w", err)
+	}
 
 	return commits, nil
 }
 
+// TotalCommits counts the commits reachable from g.h. The count only depends
+// on the hash, so it is cached indefinitely.
+func (g *GitRepo) TotalCommits() (int, error) {
+	cacheKey := fmt.Sprintf("count:%s", g.h.String())
+	cacheMu.RLock()
+	if count, found := commitCache.Get(cacheKey); found {
+		cacheMu.RUnlock()
+		return count.(int), nil
+	}
+	cacheMu.RUnlock()
+
+	cmd := exec.Command("git", "-C", g.path, "rev-list", "--count", g.h.String())
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("counting commits: %w: %s", err, strings.TrimSpace(out.String()))
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(out.String()))
+	if err != nil {
+		return 0, fmt.Errorf("parsing commit count: %w", err)
+	}
+
+	cacheMu.Lock()
+	commitCache.Set(cacheKey, count, 1)
+	cacheMu.Unlock()
+
+	return count, nil
+}
+
 func (g *GitRepo) LastCommit() (*object.Commit, error) {
 	c, err := g.r.CommitObject(g.h)
 	if err != nil {
@@ -240,12 +289,78 @@ func (g *GitRepo) FindMainBranch() (string, error) {
 	return "", fmt.Errorf("unable to find main branch: %w", err)
 }
 
-// WriteTar writes itself from a tree into a binary tar file format.
-// prefix is root folder to be appended.
-func (g *GitRepo) WriteTar(w io.Writer, prefix string) error {
+// WriteTar writes the tree at g.h as a tar archive, with every entry placed
+// under prefix. If subdir is set, only that directory is archived.
+func (g *GitRepo) WriteTar(w io.Writer, prefix, subdir string) error {
 	tw := tar.NewWriter(w)
 	defer tw.Close()
 
+	return g.walkArchive(prefix, subdir, func(info *infoWrapper, file *object.File) error {
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+
+		err = tw.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if file == nil {
+			return nil
+		}
+
+		reader, err := file.Blob.Reader()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		_, err = io.Copy(tw, reader)
+		return err
+	})
+}
+
+// WriteZip is WriteTar for zip archives.
+func (g *GitRepo) WriteZip(w io.Writer, prefix, subdir string) error {
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+
+	return g.walkArchive(prefix, subdir, func(info *infoWrapper, file *object.File) error {
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = info.name
+		if info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if file == nil {
+			return nil
+		}
+
+		reader, err := file.Blob.Reader()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		_, err = io.Copy(fw, reader)
+		return err
+	})
+}
+
+// walkArchive calls fn for every entry below subdir, with names rewritten to
+// sit under prefix. file is nil for directories.
+func (g *GitRepo) walkArchive(prefix, subdir string, fn func(info *infoWrapper, file *object.File) error) error {
 	c, err := g.r.CommitObject(g.h)
 	if err != nil {
 		return fmt.Errorf("commit object: %w", err)
@@ -256,6 +371,14 @@ func (g *GitRepo) WriteTar(w io.Writer, prefix string) error {
 		return err
 	}
 
+	subdir = strings.Trim(subdir, "/")
+	if subdir != "" {
+		tree, err = tree.Tree(subdir)
+		if err != nil {
+			return fmt.Errorf("subdirectory %s: %w", subdir, err)
+		}
+	}
+
 	walker := object.NewTreeWalker(tree, true, nil)
 	defer walker.Close()
 
@@ -266,33 +389,16 @@ func (g *GitRepo) WriteTar(w io.Writer, prefix string) error {
 			return err
 		}
 
-		header, err := tar.FileInfoHeader(info, "")
-		if err != nil {
-			return err
-		}
-
-		err = tw.WriteHeader(header)
-		if err != nil {
-			return err
-		}
-
+		var file *object.File
 		if !info.IsDir() {
-			file, err := tree.File(name)
-			if err != nil {
-				return err
-			}
-
-			reader, err := file.Blob.Reader()
+			file, err = tree.File(name)
 			if err != nil {
 				return err
 			}
+		}
 
-			_, err = io.Copy(tw, reader)
-			if err != nil {
-				reader.Close()
-				return err
-			}
-			reader.Close()
+		if err := fn(info, file); err != nil {
+			return err
 		}
 	}
 
@@ -300,7 +406,7 @@ func (g *GitRepo) WriteTar(w io.Writer, prefix string) error {
 }
 
 func (g *GitRepo) LastCommitForPath(path string) (*types.LastCommitInfo, error) {
-	cacheKey := fmt.Sprintf("%s:%s", g.h.String(), path)
+	cacheKey := lastCommitCacheKey(g.h, path)
 	cacheMu.RLock()
 	if commitInfo, found := commitCache.Get(cacheKey); found {
 		cacheMu.RUnlock()
@@ -350,6 +456,46 @@ func (g *GitRepo) LastCommitForPath(path string) (*types.LastCommitInfo, error)
 	return commitInfo, nil
 }
 
+// FileHistory lists the commits that touched path, newest first, skipping
+// the first skip of them and returning at most limit. Renames are followed
+// when path is a single file.
+func (g *GitRepo) FileHistory(path string, skip, limit int) ([]*object.Commit, error) {
+	args := []string{"-C", g.path, "log", "--format=%H",
+		fmt.Sprintf("--skip=%d", skip),
+		fmt.Sprintf("--max-count=%d", limit),
+	}
+
+	c, err := g.r.CommitObject(g.h)
+	if err != nil {
+		return nil, fmt.Errorf("commit object: %w", err)
+	}
+	if _, err := c.File(path); err == nil {
+		args = append(args, "--follow")
+	}
+	args = append(args, g.h.String(), "--", path)
+
+	cmd := exec.Command("git", args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git log: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	commits := []*object.Commit{}
+	for _, h := range strings.Fields(stdout.String()) {
+		c, err := g.r.CommitObject(plumbing.NewHash(h))
+		if err != nil {
+			return nil, fmt.Errorf("commit object: %w", err)
+		}
+		commits = append(commits, c)
+	}
+
+	return commits, nil
+}
+
 func newInfoWrapper(
 	name string,
 	prefix string,
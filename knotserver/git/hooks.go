@@ -0,0 +1,50 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HookConfig describes how knot-managed hooks call back into the knot.
+type HookConfig struct {
+	// Executable is the path to the knotserver binary.
+	Executable string
+	// InternalAPI is the base URL of the knot's internal API.
+	InternalAPI string
+}
+
+const hookHeader = "# managed by knotserver, do not edit"
+
+// InstallHooks writes the knot-managed hooks into the bare repository at
+// path, replacing any previously installed version.
+func InstallHooks(path string, c HookConfig) error {
+	hooksDir := filepath.Join(path, "hooks")
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return fmt.Errorf("creating hooks directory: %w", err)
+	}
+
+	for _, hook := range []string{"pre-receive", "post-receive"} {
+		script := fmt.Sprintf(
+			"#!/bin/sh\n%s\nexec %s hook -internal-api %s %s\n",
+			hookHeader, shellQuote(c.Executable), shellQuote(c.InternalAPI), hook,
+		)
+
+		hookPath := filepath.Join(hooksDir, hook)
+		if err := os.WriteFile(hookPath, []byte(script), 0755); err != nil {
+			return fmt.Errorf("writing %s hook: %w", hook, err)
+		}
+
+		// WriteFile doesn't change the mode of existing files
+		if err := os.Chmod(hookPath, 0755); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
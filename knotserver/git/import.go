@@ -0,0 +1,159 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// RedactUrl returns source with any credentials removed, so it can be
+// stored and shown to readers of the repo.
+func RedactUrl(source string) string {
+	u, err := url.Parse(source)
+	if err != nil || u.User == nil {
+		return source
+	}
+	u.User = nil
+	return u.String()
+}
+
+// redactCredentials removes the credentials in source from s.
+func redactCredentials(s, source string) string {
+	u, err := url.Parse(source)
+	if err != nil || u.User == nil {
+		return s
+	}
+	return strings.ReplaceAll(s, u.User.String()+"@", "")
+}
+
+// Import fetches every branch and tag of the repo at source into the bare
+// repo at path, and points HEAD at the default branch of source. Each line
+// of progress output from git is passed to progress.
+func Import(path, source string, progress func(string)) error {
+	cmd := exec.Command("git", "-C", path, "fetch", "--progress", "--prune", "--", source,
+		"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+	// never block on a credential prompt
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("fetching %s: %w", RedactUrl(source), err)
+	}
+
+	// git's error messages can span several lines
+	var last string
+	var errLines []string
+	scanner := bufio.NewScanner(stderr)
+	scanner.Split(scanProgressLines)
+	for scanner.Scan() {
+		line := redactCredentials(strings.TrimSpace(scanner.Text()), source)
+		if line == "" {
+			continue
+		}
+		last = line
+		if len(errLines) > 0 || strings.HasPrefix(line, "fatal: ") || strings.HasPrefix(line, "error: ") {
+			errLines = append(errLines, line)
+		}
+		progress(line)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		msg := last
+		if len(errLines) > 0 {
+			msg = strings.Join(errLines, " ")
+		}
+		return fmt.Errorf("fetching %s: %w: %s", RedactUrl(source), err, msg)
+	}
+
+	head, err := remoteHead(source)
+	if err != nil {
+		return err
+	}
+	if head == "" {
+		// an empty repo, or a server that doesn't advertise HEAD
+		return nil
+	}
+
+	out, err := exec.Command("git", "-C", path, "symbolic-ref", "HEAD", head).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("setting HEAD to %s: %w: %s", head, err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// Refs returns the hash every branch and tag of the repo at path points
+// to, keyed by ref name.
+func Refs(path string) (map[string]string, error) {
+	out, err := exec.Command("git", "-C", path, "for-each-ref", "--format=%(objectname) %(refname)", "refs/heads", "refs/tags").Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing refs: %w", err)
+	}
+
+	refs := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if hash, ref, ok := strings.Cut(line, " "); ok {
+			refs[ref] = hash
+		}
+	}
+
+	return refs, nil
+}
+
+// remoteHead returns the ref that HEAD points to in the repo at source.
+func remoteHead(source string) (string, error) {
+	cmd := exec.Command("git", "ls-remote", "--symref", "--", source, "HEAD")
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("listing refs of %s: %w", RedactUrl(source), err)
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		if ref, ok := strings.CutPrefix(line, "ref: "); ok {
+			ref, _, _ = strings.Cut(ref, "\t")
+			return ref, nil
+		}
+	}
+
+	return "", nil
+}
+
+// scanProgressLines is like bufio.ScanLines, but also splits on the carriage
+// returns git uses to redraw progress meters.
+func scanProgressLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// PushMirror force pushes every ref of the repo at path to remote, and
+// deletes remote refs that no longer exist locally. env is added to the
+// environment of git, e.g. to pass credentials.
+func PushMirror(path, remote string, env []string) error {
+	cmd := exec.Command("git", "-C", path, "push", "--mirror", "--", remote)
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), env...)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := redactCredentials(strings.TrimSpace(string(out)), remote)
+		return fmt.Errorf("pushing to %s: %w: %s", RedactUrl(remote), err, msg)
+	}
+
+	return nil
+}
@@ -0,0 +1,246 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/sotangled/tangled/types"
+)
+
+// ErrMerge is returned when a patch does not apply cleanly on top of the
+// target branch.
+type ErrMerge struct {
+	Message   string
+	Conflicts []types.ConflictInfo
+}
+
+func (e ErrMerge) Error() string {
+	if len(e.Conflicts) == 0 {
+		return e.Message
+	}
+
+	var files []string
+	for _, c := range e.Conflicts {
+		files = append(files, c.Filename)
+	}
+	return fmt.Sprintf("%s: %s", e.Message, strings.Join(files, ", "))
+}
+
+// MergeCheck applies the patch to a scratch index built from the tree at
+// g.h. The repository itself is left untouched.
+func (g *GitRepo) MergeCheck(patch []byte) error {
+	indexDir, err := os.MkdirTemp("", "tangled-index-")
+	if err != nil {
+		return fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(indexDir)
+
+	env := append(os.Environ(), "GIT_INDEX_FILE="+filepath.Join(indexDir, "index"))
+
+	if _, err := g.runGit(env, nil, "read-tree", g.h.String()); err != nil {
+		return err
+	}
+
+	return g.applyCached(env, patch, true)
+}
+
+type MergeOptions struct {
+	AuthorName     string
+	AuthorEmail    string
+	CommitterName  string
+	CommitterEmail string
+	CommitMessage  string
+
+	// NoFastForward records the patch on top of the branch and then joins
+	// the two with a merge commit, instead of advancing the branch directly.
+	NoFastForward bool
+
+	// BeforeUpdate, if set, is called with the old and new head of the
+	// branch right before it is moved, much like a pre-receive hook. An
+	// error aborts the merge and is returned as is.
+	BeforeUpdate func(old, new string) error
+}
+
+// Merge applies the patch on top of branch and moves the branch to the
+// resulting commit. Author and message are taken from the patch header when
+// it was produced by git-format-patch, and from opts otherwise. The new head
+// is returned.
+func (g *GitRepo) Merge(patch []byte, branch string, opts MergeOptions) (string, error) {
+	indexDir, err := os.MkdirTemp("", "tangled-index-")
+	if err != nil {
+		return "", fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(indexDir)
+
+	env := append(os.Environ(), "GIT_INDEX_FILE="+filepath.Join(indexDir, "index"))
+	old := g.h.String()
+
+	if _, err := g.runGit(env, nil, "read-tree", old); err != nil {
+		return "", err
+	}
+
+	if err := g.applyCached(env, patch, false); err != nil {
+		return "", err
+	}
+
+	tree, err := g.runGit(env, nil, "write-tree")
+	if err != nil {
+		return "", err
+	}
+
+	authorName, authorEmail, message := opts.AuthorName, opts.AuthorEmail, opts.CommitMessage
+	authorDate := time.Now()
+	if _, preamble, err := gitdiff.Parse(bytes.NewReader(patch)); err == nil {
+		if header, err := gitdiff.ParsePatchHeader(preamble); err == nil {
+			if header.Author != nil {
+				authorName, authorEmail = header.Author.Name, header.Author.Email
+			}
+			if !header.AuthorDate.IsZero() {
+				authorDate = header.AuthorDate
+			}
+			if m := header.Message(); m != "" {
+				message = m
+			}
+		}
+	}
+	if message == "" {
+		message = "Apply patch"
+	}
+
+	commitEnv := append(env,
+		"GIT_AUTHOR_NAME="+authorName,
+		"GIT_AUTHOR_EMAIL="+authorEmail,
+		"GIT_AUTHOR_DATE="+authorDate.Format(time.RFC3339),
+		"GIT_COMMITTER_NAME="+opts.CommitterName,
+		"GIT_COMMITTER_EMAIL="+opts.CommitterEmail,
+	)
+
+	head, err := g.runGit(commitEnv, []byte(message), "commit-tree", tree, "-p", old)
+	if err != nil {
+		return "", err
+	}
+
+	if opts.NoFastForward {
+		mergeMessage := opts.CommitMessage
+		if mergeMessage == "" {
+			mergeMessage = fmt.Sprintf("Merge patch into %s", branch)
+		}
+		mergeEnv := append(env,
+			"GIT_AUTHOR_NAME="+opts.CommitterName,
+			"GIT_AUTHOR_EMAIL="+opts.CommitterEmail,
+			"GIT_COMMITTER_NAME="+opts.CommitterName,
+			"GIT_COMMITTER_EMAIL="+opts.CommitterEmail,
+		)
+		head, err = g.runGit(mergeEnv, []byte(mergeMessage), "commit-tree", tree, "-p", old, "-p", head)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if opts.BeforeUpdate != nil {
+		if err := opts.BeforeUpdate(old, head); err != nil {
+			return "", err
+		}
+	}
+
+	// passing the old value makes this fail if the branch moved since we
+	// read it, rather than silently dropping someone's push
+	if _, err := g.runGit(env, nil, "update-ref", "refs/heads/"+branch, head, old); err != nil {
+		return "", err
+	}
+
+	return head, nil
+}
+
+// applyCached runs git-apply against the index pointed to by env. Failures
+// caused by the patch not applying are reported as ErrMerge.
+func (g *GitRepo) applyCached(env []string, patch []byte, checkOnly bool) error {
+	args := []string{"apply", "--cached"}
+	if checkOnly {
+		args = append(args, "--check")
+	}
+	args = append(args, "-")
+
+	cmd := exec.Command("git", append([]string{"-C", g.path}, args...)...)
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(patch)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		conflicts := parseApplyErrors(stderr.String())
+		if len(conflicts) == 0 {
+			return fmt.Errorf("git apply: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return ErrMerge{
+			Message:   "patch does not apply cleanly",
+			Conflicts: conflicts,
+		}
+	}
+
+	return nil
+}
+
+func (g *GitRepo) runGit(env []string, stdin []byte, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", g.path}, args...)...)
+	cmd.Env = env
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// parseApplyErrors turns git-apply's stderr into one entry per file. Lines
+// that do not name a file (corrupt patches and the like) are ignored.
+func parseApplyErrors(stderr string) []types.ConflictInfo {
+	var conflicts []types.ConflictInfo
+	seen := make(map[string]bool)
+
+	for _, line := range strings.Split(stderr, "\n") {
+		line, ok := strings.CutPrefix(strings.TrimSpace(line), "error: ")
+		if !ok {
+			continue
+		}
+
+		var c types.ConflictInfo
+		if rest, ok := strings.CutPrefix(line, "patch failed: "); ok {
+			idx := strings.LastIndex(rest, ":")
+			if idx < 0 {
+				continue
+			}
+			c.Filename = rest[:idx]
+			c.Reason = fmt.Sprintf("patch failed at line %s", rest[idx+1:])
+		} else {
+			idx := strings.Index(line, ": ")
+			if idx < 0 {
+				continue
+			}
+			c.Filename = line[:idx]
+			c.Reason = line[idx+2:]
+		}
+
+		if seen[c.Filename] {
+			continue
+		}
+		seen[c.Filename] = true
+		conflicts = append(conflicts, c)
+	}
+
+	return conflicts
+}
@@ -11,7 +11,7 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 )
 
-func InitBare(path, defaultBranch string) error {
+func InitBare(path, defaultBranch string, hooks HookConfig) error {
 	parent := filepath.Dir(path)
 
 	if err := os.MkdirAll(parent, 0755); errors.Is(err, os.ErrExist) {
@@ -38,5 +38,10 @@ func InitBare(path, defaultBranch string) error {
 		return fmt.Errorf("creating symbolic reference: %w", err)
 	}
 
+	if err := InstallHooks(path, hooks); err != nil {
+		os.RemoveAll(path)
+		return fmt.Errorf("installing hooks: %w", err)
+	}
+
 	return nil
 }
@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"os/exec"
 	"strings"
 	"syscall"
@@ -15,36 +16,73 @@ import (
 
 type ServiceCommand struct {
 	Dir    string
+	Env    []string
 	Stdin  io.Reader
 	Stdout http.ResponseWriter
+
+	// GitProtocol is the value of the client's Git-Protocol header, passed
+	// to git as GIT_PROTOCOL. It is used to negotiate protocol v2.
+	GitProtocol string
+}
+
+func (c *ServiceCommand) environ() []string {
+	env := append(os.Environ(), c.Env...)
+	if c.GitProtocol != "" {
+		env = append(env, "GIT_PROTOCOL="+c.GitProtocol)
+	}
+	return env
+}
+
+func (c *ServiceCommand) isProtocolV2() bool {
+	for _, param := range strings.Split(c.GitProtocol, ":") {
+		if param == "version=2" {
+			return true
+		}
+	}
+	return false
 }
 
+// InfoRefs writes the ref advertisement for git-upload-pack.
 func (c *ServiceCommand) InfoRefs() error {
+	return c.infoRefs("upload-pack")
+}
+
+// ReceivePackInfoRefs writes the ref advertisement for git-receive-pack.
+func (c *ServiceCommand) ReceivePackInfoRefs() error {
+	return c.infoRefs("receive-pack")
+}
+
+func (c *ServiceCommand) infoRefs(service string) error {
 	cmd := exec.Command("git", []string{
-		"upload-pack",
+		service,
 		"--stateless-rpc",
 		"--advertise-refs",
 		".",
 	}...)
 
 	cmd.Dir = c.Dir
+	cmd.Env = c.environ()
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	stdoutPipe, _ := cmd.StdoutPipe()
 	cmd.Stderr = cmd.Stdout
 
 	if err := cmd.Start(); err != nil {
-		log.Printf("git: failed to start git-upload-pack (info/refs): %s", err)
+		log.Printf("git: failed to start git-%s (info/refs): %s", service, err)
 		return err
 	}
 
-	if err := packLine(c.Stdout, "# service=git-upload-pack\n"); err != nil {
-		log.Printf("git: failed to write pack line: %s", err)
-		return err
-	}
-
-	if err := packFlush(c.Stdout); err != nil {
-		log.Printf("git: failed to flush pack: %s", err)
-		return err
+	// v2 clients expect the capability advertisement without the
+	// smart http service header.
+	if !c.isProtocolV2() {
+		if err := packLine(c.Stdout, fmt.Sprintf("# service=git-%s\n", service)); err != nil {
+			log.Printf("git: failed to write pack line: %s", err)
+			return err
+		}
+
+		if err := packFlush(c.Stdout); err != nil {
+			log.Printf("git: failed to flush pack: %s", err)
+			return err
+		}
 	}
 
 	buf := bytes.Buffer{}
@@ -56,7 +94,7 @@ func (c *ServiceCommand) InfoRefs() error {
 	if err := cmd.Wait(); err != nil {
 		out := strings.Builder{}
 		_, _ = io.Copy(&out, &buf)
-		log.Printf("git: failed to run git-upload-pack; err: %s; output: %s", err, out.String())
+		log.Printf("git: failed to run git-%s; err: %s; output: %s", service, err, out.String())
 		return err
 	}
 
@@ -68,13 +106,18 @@ func (c *ServiceCommand) InfoRefs() error {
 }
 
 func (c *ServiceCommand) UploadPack() error {
-	cmd := exec.Command("git", []string{
-		"-c", "uploadpack.allowFilter=true",
-		"upload-pack",
-		"--stateless-rpc",
-		".",
-	}...)
+	return c.rpc("upload-pack", "-c", "uploadpack.allowFilter=true")
+}
+
+func (c *ServiceCommand) ReceivePack() error {
+	return c.rpc("receive-pack")
+}
+
+func (c *ServiceCommand) rpc(service string, config ...string) error {
+	args := append(config, service, "--stateless-rpc", ".")
+	cmd := exec.Command("git", args...)
 	cmd.Dir = c.Dir
+	cmd.Env = c.environ()
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
 	stdoutPipe, _ := cmd.StdoutPipe()
@@ -88,7 +131,7 @@ func (c *ServiceCommand) UploadPack() error {
 	defer stdinPipe.Close()
 
 	if err := cmd.Start(); err != nil {
-		log.Printf("git: failed to start git-upload-pack: %s", err)
+		log.Printf("git: failed to start git-%s: %s", service, err)
 		return err
 	}
 
@@ -103,7 +146,7 @@ func (c *ServiceCommand) UploadPack() error {
 		return err
 	}
 	if err := cmd.Wait(); err != nil {
-		log.Printf("git: failed to wait for git-upload-pack: %s", err)
+		log.Printf("git: failed to wait for git-%s: %s", service, err)
 		return err
 	}
 
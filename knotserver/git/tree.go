@@ -1,8 +1,15 @@
 package git
 
 import (
+	"bufio"
 	"fmt"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/sotangled/tangled/types"
 )
@@ -40,24 +47,36 @@ func (g *GitRepo) FileTree(path string) ([]types.NiceTree, error) {
 	return files, nil
 }
 
+// IsDir reports whether path is a directory in the tree at g.h.
+func (g *GitRepo) IsDir(path string) bool {
+	c, err := g.r.CommitObject(g.h)
+	if err != nil {
+		return false
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return false
+	}
+
+	_, err = tree.Tree(strings.Trim(path, "/"))
+	return err == nil
+}
+
 func (g *GitRepo) makeNiceTree(t *object.Tree, parent string) []types.NiceTree {
 	nts := []types.NiceTree{}
 
+	lastCommits, err := g.lastCommitsInTree(t, parent)
+	if err != nil {
+		fmt.Println("error getting last commit time:", err)
+	}
+
 	for _, e := range t.Entries {
 		mode, _ := e.Mode.ToOSFileMode()
 		sz, _ := t.Size(e.Name)
 
-		var fpath string
-		if parent != "" {
-			fpath = fmt.Sprintf("%s/%s", parent, e.Name)
-		} else {
-			fpath = e.Name
-		}
-		lastCommit, err := g.LastCommitForPath(fpath)
-		if err != nil {
-			fmt.Println("error getting last commit time:", err)
-			continue
-		}
+		// entries are still listed when their last commit can't be found
+		lastCommit := lastCommits[e.Name]
 
 		nts = append(nts, types.NiceTree{
 			Name:       e.Name,
@@ -71,3 +90,106 @@ func (g *GitRepo) makeNiceTree(t *object.Tree, parent string) []types.NiceTree {
 
 	return nts
 }
+
+// lastCommitsInTree finds the last commit to touch each entry of t, keyed by
+// entry name. Cached entries are used as-is; the rest are filled in from a
+// single `git log` over parent, which is cut short once every entry is seen.
+func (g *GitRepo) lastCommitsInTree(t *object.Tree, parent string) (map[string]*types.LastCommitInfo, error) {
+	found := make(map[string]*types.LastCommitInfo)
+	missing := make(map[string]bool)
+
+	for _, e := range t.Entries {
+		cacheMu.RLock()
+		commitInfo, ok := commitCache.Get(lastCommitCacheKey(g.h, path.Join(parent, e.Name)))
+		cacheMu.RUnlock()
+		if ok {
+			found[e.Name] = commitInfo.(*types.LastCommitInfo)
+		} else {
+			missing[e.Name] = true
+		}
+	}
+
+	if len(missing) == 0 {
+		return found, nil
+	}
+
+	pathspec := parent
+	if pathspec == "" {
+		pathspec = "."
+	}
+
+	cmd := exec.Command("git", "-C", g.path, "-c", "core.quotePath=false",
+		"log", "--no-renames", "--name-only", "--format=%x00%H %ct",
+		g.h.String(), "--", pathspec)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return found, err
+	}
+	if err := cmd.Start(); err != nil {
+		return found, fmt.Errorf("starting git log: %w", err)
+	}
+
+	var current *types.LastCommitInfo
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() && len(missing) > 0 {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		// headers start with a NUL, which can't appear in file names
+		if header, ok := strings.CutPrefix(line, "\x00"); ok {
+			hash, ts, _ := strings.Cut(header, " ")
+			unix, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				continue
+			}
+			current = &types.LastCommitInfo{
+				Hash: plumbing.NewHash(hash),
+				When: time.Unix(unix, 0),
+			}
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		if strings.HasPrefix(line, "\"") {
+			if unquoted, err := strconv.Unquote(line); err == nil {
+				line = unquoted
+			}
+		}
+
+		rel := line
+		if parent != "" {
+			var ok bool
+			rel, ok = strings.CutPrefix(line, parent+"/")
+			if !ok {
+				continue
+			}
+		}
+		name, _, _ := strings.Cut(rel, "/")
+
+		if missing[name] {
+			delete(missing, name)
+			found[name] = current
+
+			cacheMu.Lock()
+			commitCache.Set(lastCommitCacheKey(g.h, path.Join(parent, name)), current, 1)
+			cacheMu.Unlock()
+		}
+	}
+
+	// everything we need has been seen; no point letting git walk the rest
+	// of the history
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	return found, nil
+}
+
+func lastCommitCacheKey(h plumbing.Hash, path string) string {
+	return fmt.Sprintf("%s:%s", h.String(), path)
+}
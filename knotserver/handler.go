@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sync"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/sotangled/tangled/jetstream"
@@ -28,6 +29,9 @@ type Handle struct {
 	// i.e. when the first user (knot owner) has been added.
 	init            chan struct{}
 	knotInitialized bool
+
+	// repos with a mirror sync in progress
+	syncing sync.Map
 }
 
 func Setup(ctx context.Context, c *config.Config, db *db.DB, e *rbac.Enforcer, jc *jetstream.JetstreamClient, l *slog.Logger) (http.Handler, error) {
@@ -47,6 +51,24 @@ func Setup(ctx context.Context, c *config.Config, db *db.DB, e *rbac.Enforcer, j
 		return nil, fmt.Errorf("failed to setup enforcer: %w", err)
 	}
 
+	// repos created before repo:read existed are public
+	if err := e.BackfillReadPolicies(); err != nil {
+		return nil, fmt.Errorf("failed to backfill read policies: %w", err)
+	}
+	if err := e.BackfillRoles(); err != nil {
+		return nil, fmt.Errorf("failed to backfill roles: %w", err)
+	}
+
+	if err := db.InterruptImports(); err != nil {
+		return nil, fmt.Errorf("failed to mark interrupted imports: %w", err)
+	}
+
+	if err := h.installHooks(); err != nil {
+		return nil, fmt.Errorf("failed to install hooks: %w", err)
+	}
+
+	go h.syncMirrors(ctx)
+
 	err = h.jc.StartJetstream(ctx, h.processMessages)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start jetstream: %w", err)
@@ -69,26 +91,53 @@ func Setup(ctx context.Context, c *config.Config, db *db.DB, e *rbac.Enforcer, j
 	r.Route("/{did}", func(r chi.Router) {
 		// Repo routes
 		r.Route("/{name}", func(r chi.Router) {
-			r.Post("/collaborator/add", h.AddRepoCollaborator)
+			r.Route("/collaborator", func(r chi.Router) {
+				r.Use(h.VerifySignature)
+				r.Post("/add", h.AddRepoCollaborator)
+				r.Post("/remove", h.RemoveRepoCollaborator)
+				r.Post("/role", h.SetRepoCollaboratorRole)
+			})
 
-			r.Get("/", h.RepoIndex)
-			r.Get("/info/refs", h.InfoRefs)
-			r.Post("/git-upload-pack", h.UploadPack)
+			// everything below can only be read by those with repo:read
+			r.Group(func(r chi.Router) {
+				r.Use(h.VerifyRead)
 
-			r.Route("/tree/{ref}", func(r chi.Router) {
 				r.Get("/", h.RepoIndex)
-				r.Get("/*", h.RepoTree)
+				r.Get("/info/refs", h.InfoRefs)
+				r.Post("/git-upload-pack", h.UploadPack)
+				r.With(h.VerifyPush).Post("/git-receive-pack", h.ReceivePack)
+
+				r.Route("/tree/{ref}", func(r chi.Router) {
+					r.Get("/", h.RepoIndex)
+					r.Get("/*", h.RepoTree)
+				})
+
+				r.Route("/blob/{ref}", func(r chi.Router) {
+					r.Get("/*", h.Blob)
+				})
+
+				r.Route("/blame/{ref}", func(r chi.Router) {
+					r.Get("/*", h.Blame)
+				})
+
+				r.Route("/raw/{ref}", func(r chi.Router) {
+					r.Get("/*", h.Raw)
+				})
+
+				r.Get("/log/{ref}", h.Log)
+				r.Get("/history/{ref}/*", h.History)
+				r.Get("/archive/{file}", h.Archive)
+				r.Get("/commit/{ref}", h.Diff)
+				r.Get("/compare/*", h.Compare)
+				r.Get("/tags", h.Tags)
+				r.Get("/branches", h.Branches)
+				r.Get("/branch-rules", h.BranchRules)
+				r.Get("/import", h.ImportStatus)
+				r.Get("/mirror", h.Mirror)
+				// mirror urls are only for the appview's eyes
+				r.With(h.VerifySignature).Get("/push-mirrors", h.PushMirrors)
+				r.With(h.VerifySignature).Get("/bundle", h.Bundle)
 			})
-
-			r.Route("/blob/{ref}", func(r chi.Router) {
-				r.Get("/*", h.Blob)
-			})
-
-			r.Get("/log/{ref}", h.Log)
-			r.Get("/archive/{file}", h.Archive)
-			r.Get("/commit/{ref}", h.Diff)
-			r.Get("/tags", h.Tags)
-			r.Get("/branches", h.Branches)
 		})
 	})
 
@@ -96,12 +145,25 @@ func Setup(ctx context.Context, c *config.Config, db *db.DB, e *rbac.Enforcer, j
 	r.Route("/repo", func(r chi.Router) {
 		r.Use(h.VerifySignature)
 		r.Put("/new", h.NewRepo)
+		r.Put("/fork", h.ForkRepo)
+		r.Put("/import", h.ImportRepo)
+		r.Put("/mirror", h.SetMirror)
+		r.Delete("/mirror", h.RemoveMirror)
+		r.Put("/push-mirrors", h.AddPushMirror)
+		r.Delete("/push-mirrors", h.RemovePushMirror)
 		r.Delete("/", h.RemoveRepo)
+		r.Post("/rename", h.RenameRepo)
+		r.Put("/bundle", h.ImportBundle)
+		r.Post("/merge", h.Merge)
+		r.Post("/merge/check", h.MergeCheck)
+		r.Put("/branch-rules", h.AddBranchRule)
+		r.Delete("/branch-rules", h.RemoveBranchRule)
 	})
 
 	r.Route("/member", func(r chi.Router) {
 		r.Use(h.VerifySignature)
 		r.Put("/add", h.AddMember)
+		r.Delete("/remove", h.RemoveMember)
 	})
 
 	// Initialize the knot with an owner and public key.
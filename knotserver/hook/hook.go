@@ -0,0 +1,148 @@
+// Package hook implements the client side of the knot-managed git hooks.
+// Hooks are run by git inside the repository and report back to the knot
+// over its internal API.
+package hook
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// RefUpdate is a single line of pre- or post-receive input.
+type RefUpdate struct {
+	OldSha string `json:"oldSha"`
+	NewSha string `json:"newSha"`
+	Ref    string `json:"ref"`
+	// Forced is set by the pre-receive hook when NewSha does not descend
+	// from OldSha.
+	Forced bool `json:"forced,omitempty"`
+}
+
+func isZero(sha string) bool {
+	return strings.Trim(sha, "0") == ""
+}
+
+func (u RefUpdate) IsDeletion() bool {
+	return isZero(u.NewSha)
+}
+
+// HookRequest is sent to the internal API by the pre- and post-receive
+// hooks.
+type HookRequest struct {
+	// RepoPath is the absolute path to the bare repository.
+	RepoPath string `json:"repoPath"`
+	// Pusher is the DID of the user who pushed, if known.
+	Pusher  string      `json:"pusher"`
+	Updates []RefUpdate `json:"updates"`
+}
+
+// PusherEnv is the environment variable the knot sets to the DID of the
+// user running git-receive-pack.
+const PusherEnv = "GIT_USER_DID"
+
+// ErrRejected wraps the reason the knot gave for rejecting a push.
+var ErrRejected = errors.New("push rejected")
+
+// PreReceive reads ref updates from stdin and asks the internal API whether
+// they may be applied. A rejection is returned as an error wrapping
+// ErrRejected.
+func PreReceive(internalAPI string, stdin io.Reader) error {
+	updates, err := parseRefUpdates(stdin)
+	if err != nil {
+		return err
+	}
+
+	for i, u := range updates {
+		if isZero(u.OldSha) || isZero(u.NewSha) {
+			continue
+		}
+		// new objects are only visible to git processes started by the hook,
+		// so this has to run here rather than on the knot
+		err := exec.Command("git", "merge-base", "--is-ancestor", u.OldSha, u.NewSha).Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			updates[i].Forced = true
+		} else if err != nil {
+			return fmt.Errorf("checking ancestry of %s: %w", u.Ref, err)
+		}
+	}
+
+	return report(internalAPI, "pre-receive", updates)
+}
+
+// PostReceive reads ref updates from stdin, as passed to the post-receive
+// hook by git, and reports them to the internal API.
+func PostReceive(internalAPI string, stdin io.Reader) error {
+	updates, err := parseRefUpdates(stdin)
+	if err != nil {
+		return err
+	}
+
+	return report(internalAPI, "post-receive", updates)
+}
+
+func report(internalAPI, hook string, updates []RefUpdate) error {
+	repoPath, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(HookRequest{
+		RepoPath: repoPath,
+		Pusher:   os.Getenv(PusherEnv),
+		Updates:  updates,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(
+		strings.TrimSuffix(internalAPI, "/")+"/hooks/"+hook,
+		"application/json",
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusForbidden:
+		var data struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&data)
+		return fmt.Errorf("%w: %s", ErrRejected, data.Error)
+	default:
+		return fmt.Errorf("internal api returned %s", resp.Status)
+	}
+}
+
+func parseRefUpdates(r io.Reader) ([]RefUpdate, error) {
+	var updates []RefUpdate
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed ref update: %q", scanner.Text())
+		}
+		updates = append(updates, RefUpdate{
+			OldSha: fields[0],
+			NewSha: fields[1],
+			Ref:    fields[2],
+		})
+	}
+
+	return updates, scanner.Err()
+}
@@ -0,0 +1,120 @@
+package knotserver
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/sotangled/tangled/knotserver/config"
+	"github.com/sotangled/tangled/knotserver/db"
+	"github.com/sotangled/tangled/knotserver/git"
+	"github.com/sotangled/tangled/knotserver/hook"
+	"github.com/sotangled/tangled/rbac"
+)
+
+func (h *Handle) hookConfig() (git.HookConfig, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return git.HookConfig{}, fmt.Errorf("locating knotserver executable: %w", err)
+	}
+
+	return git.HookConfig{
+		Executable:  executable,
+		InternalAPI: "http://" + h.c.Server.InternalListenAddr,
+	}, nil
+}
+
+// installHooks (re)installs the knot-managed hooks into every repository
+// under the scan path, so that existing repos pick up hook changes.
+func (h *Handle) installHooks() error {
+	hooks, err := h.hookConfig()
+	if err != nil {
+		return err
+	}
+
+	repos, err := filepath.Glob(filepath.Join(h.c.Repo.ScanPath, "*", "*"))
+	if err != nil {
+		return err
+	}
+
+	for _, repo := range repos {
+		if _, err := os.Stat(filepath.Join(repo, "HEAD")); err != nil {
+			continue
+		}
+		if err := git.InstallHooks(repo, hooks); err != nil {
+			return fmt.Errorf("installing hooks into %s: %w", repo, err)
+		}
+	}
+
+	return nil
+}
+
+// pushRejected is returned by checkPush when an update is not allowed, as
+// opposed to the check itself failing.
+type pushRejected struct {
+	reason string
+}
+
+func (e pushRejected) Error() string {
+	return e.reason
+}
+
+// checkPush decides whether pusher may apply updates to repo. It backs the
+// pre-receive hook, and merges made by the knot itself.
+func checkPush(d *db.DB, e *rbac.Enforcer, repo, pusher string, updates []hook.RefUpdate) error {
+	// repoguard turns away ssh pushes to mirrors, this catches the rest
+	m, err := d.GetMirror(repo)
+	if err == nil {
+		return pushRejected{fmt.Sprintf("this repository is a mirror of %s, push there instead", git.RedactUrl(m.Source))}
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("getting mirror: %w", err)
+	}
+
+	rules, err := d.GetBranchRules(repo)
+	if err != nil {
+		return fmt.Errorf("getting branch rules: %w", err)
+	}
+
+	isOwner, _ := e.IsRepoOwner(pusher, ThisServer, repo)
+	for _, u := range updates {
+		if err := checkBranchRules(rules, u, isOwner); err != nil {
+			return pushRejected{err.Error()}
+		}
+	}
+
+	return nil
+}
+
+// recordPush runs everything that follows a push to repo: the updates are
+// logged, the appview is told about them, and push mirrors are brought up
+// to date. It backs the post-receive hook, and merges made by the knot.
+func recordPush(c *config.Config, d *db.DB, l *slog.Logger, repo, pusher string, updates []hook.RefUpdate) error {
+	for _, u := range updates {
+		l.Info("ref updated", "repo", repo, "pusher", pusher, "ref", u.Ref, "old", u.OldSha, "new", u.NewSha)
+
+		err := d.AddRefUpdate(db.RefUpdate{
+			Repo:   repo,
+			Pusher: pusher,
+			Ref:    u.Ref,
+			OldSha: u.OldSha,
+			NewSha: u.NewSha,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		if err := notifyPush(c, repo, pusher, updates); err != nil {
+			l.Error("notifying appview of push", "repo", repo, "error", err)
+		}
+	}()
+
+	go pushMirrors(c, d, l, repo)
+
+	return nil
+}
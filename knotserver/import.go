@@ -0,0 +1,222 @@
+package knotserver
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/go-chi/chi/v5"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/sotangled/tangled/knotserver/git"
+	"github.com/sotangled/tangled/types"
+)
+
+// ImportRepo creates a repo and starts fetching every ref of the repo at
+// the given clone url into it. Progress can be followed with ImportStatus.
+func (h *Handle) ImportRepo(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "ImportRepo")
+
+	data := struct {
+		Did     string `json:"did"`
+		Name    string `json:"name"`
+		Source  string `json:"source"`
+		Private bool   `json:"private,omitempty"`
+		// keep the repo in sync with source after importing it
+		Mirror bool `json:"mirror,omitempty"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if data.Did == "" || data.Name == "" || data.Source == "" {
+		writeError(w, "did, name and source are required", http.StatusBadRequest)
+		return
+	}
+
+	h.startImport(w, l, data.Did, data.Name, data.Source, data.Private, data.Mirror)
+}
+
+// startImport creates the repo and its policies, and starts fetching source
+// into it in the background.
+func (h *Handle) startImport(w http.ResponseWriter, l *slog.Logger, did, name, source string, private, mirror bool) {
+	if err := h.checkImportSource(source); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hooks, err := h.hookConfig()
+	if err != nil {
+		l.Error("building hook config", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	relativeRepoPath := filepath.Join(did, name)
+	repoPath, _ := securejoin.SecureJoin(h.c.Repo.ScanPath, relativeRepoPath)
+
+	// HEAD is pointed at the default branch of the source once it is fetched
+	err = git.InitBare(repoPath, h.c.Repo.MainBranch, hooks)
+	if err != nil {
+		l.Error("initializing bare repo", "error", err.Error())
+		if errors.Is(err, gogit.ErrRepositoryAlreadyExists) {
+			writeError(w, "That repo already exists!", http.StatusConflict)
+			return
+		} else {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = h.e.AddRepo(did, ThisServer, relativeRepoPath)
+	if err != nil {
+		l.Error("adding repo permissions", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !private {
+		if err := h.e.MakeRepoPublic(ThisServer, relativeRepoPath); err != nil {
+			l.Error("making repo public", "error", err.Error())
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// import status is readable by anyone who can read the repo
+	if err := h.db.StartImport(relativeRepoPath, git.RedactUrl(source)); err != nil {
+		l.Error("recording import", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	go h.runImport(relativeRepoPath, repoPath, source, mirror)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handle) runImport(repo, repoPath, source string, mirror bool) {
+	l := h.l.With("handler", "runImport", "repo", repo)
+
+	var last string
+	var lastUpdate time.Time
+	err := git.Import(repoPath, source, func(line string) {
+		last = line
+		// progress meters are redrawn many times a second
+		if time.Since(lastUpdate) < time.Second {
+			return
+		}
+		lastUpdate = time.Now()
+		if err := h.db.UpdateImportProgress(repo, line); err != nil {
+			l.Error("updating import progress", "error", err.Error())
+		}
+	})
+
+	if last != "" {
+		if err := h.db.UpdateImportProgress(repo, last); err != nil {
+			l.Error("updating import progress", "error", err.Error())
+		}
+	}
+
+	status, importErr := types.ImportSucceeded, ""
+	if err != nil {
+		l.Error("importing repo", "error", err.Error())
+		status, importErr = types.ImportFailed, err.Error()
+	} else {
+		l.Info("imported repo")
+	}
+
+	if err := h.db.FinishImport(repo, status, importErr); err != nil {
+		l.Error("recording import result", "error", err.Error())
+	}
+
+	if mirror && err == nil {
+		if err := h.db.SetMirror(repo, source, defaultMirrorInterval); err != nil {
+			l.Error("setting mirror", "error", err.Error())
+			return
+		}
+		if err := h.db.UpdateMirrorSync(repo, time.Now(), ""); err != nil {
+			l.Error("recording mirror sync", "error", err.Error())
+		}
+	}
+}
+
+func (h *Handle) ImportStatus(w http.ResponseWriter, r *http.Request) {
+	repo, _ := securejoin.SecureJoin(chi.URLParam(r, "did"), chi.URLParam(r, "name"))
+
+	imp, err := h.db.GetImport(repo)
+	if errors.Is(err, sql.ErrNoRows) {
+		notFound(w)
+		return
+	}
+	if err != nil {
+		h.l.Error("getting import", "repo", repo, "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// imports started before sources were redacted on the way in
+	imp.Source = git.RedactUrl(imp.Source)
+	writeJSON(w, imp)
+}
+
+// checkImportSource only allows importing from remote repos.
+func (h *Handle) checkImportSource(source string) error {
+	return checkRemote(source, h.c.Server.Dev)
+}
+
+// checkRemote only allows remote repos on public addresses. Local paths
+// could name any repo on the knot, and internal addresses would reach the
+// knot's internal API or its network, so both are only allowed in dev mode.
+// Remotes are checked again before every mirror sync or push, in case their
+// names have started resolving elsewhere.
+func checkRemote(source string, dev bool) error {
+	if dev && (strings.HasPrefix(source, "file://") || filepath.IsAbs(source)) {
+		return nil
+	}
+
+	u, err := url.Parse(source)
+	if err != nil || u.Hostname() == "" || !slices.Contains([]string{"https", "http", "git"}, u.Scheme) {
+		return fmt.Errorf("unsupported clone url: %s", git.RedactUrl(source))
+	}
+
+	if dev {
+		return nil
+	}
+
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", u.Hostname(), err)
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return fmt.Errorf("%s resolves to a private or loopback address", u.Hostname())
+		}
+	}
+
+	return nil
+}
+
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified() &&
+		!cgnat.Contains(ip)
+}
@@ -2,17 +2,29 @@ package knotserver
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"path/filepath"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/sotangled/tangled/knotserver/config"
 	"github.com/sotangled/tangled/knotserver/db"
+	"github.com/sotangled/tangled/knotserver/git"
+	"github.com/sotangled/tangled/knotserver/hook"
 	"github.com/sotangled/tangled/rbac"
 )
 
 type InternalHandle struct {
+	c  *config.Config
 	db *db.DB
 	e  *rbac.Enforcer
+	l  *slog.Logger
 }
 
 func (h *InternalHandle) PushAllowed(w http.ResponseWriter, r *http.Request) {
@@ -34,6 +46,47 @@ func (h *InternalHandle) PushAllowed(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+func (h *InternalHandle) ReadAllowed(w http.ResponseWriter, r *http.Request) {
+	user := r.URL.Query().Get("user")
+	repo := r.URL.Query().Get("repo")
+
+	if repo == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ok, err := h.e.IsReadAllowed(user, ThisServer, repo)
+	if err != nil || !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Mirror returns the upstream of a mirrored repo, or 404 if the repo is
+// not a mirror.
+func (h *InternalHandle) Mirror(w http.ResponseWriter, r *http.Request) {
+	repo := r.URL.Query().Get("repo")
+	if repo == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	m, err := h.db.GetMirror(repo)
+	if errors.Is(err, sql.ErrNoRows) {
+		notFound(w)
+		return
+	}
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	m.Source = git.RedactUrl(m.Source)
+	writeJSON(w, m)
+}
+
 func (h *InternalHandle) InternalKeys(w http.ResponseWriter, r *http.Request) {
 	keys, err := h.db.GetAllPublicKeys()
 	if err != nil {
@@ -50,16 +103,101 @@ func (h *InternalHandle) InternalKeys(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-func Internal(ctx context.Context, db *db.DB, e *rbac.Enforcer) http.Handler {
+func (h *InternalHandle) PreReceive(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "PreReceive")
+
+	var data hook.HookRequest
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	repo, err := repoFromPath(h.c.Repo.ScanPath, data.RepoPath)
+	if err != nil {
+		l.Error("resolving repo", "path", data.RepoPath, "error", err)
+		writeError(w, "unknown repository", http.StatusBadRequest)
+		return
+	}
+
+	if err := checkPush(h.db, h.e, repo, data.Pusher, data.Updates); err != nil {
+		var rejected pushRejected
+		if errors.As(err, &rejected) {
+			l.Info("push rejected", "repo", repo, "pusher", data.Pusher, "reason", err)
+			writeError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		l.Error("checking push", "repo", repo, "error", err)
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *InternalHandle) PostReceive(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "PostReceive")
+
+	var data hook.HookRequest
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	repo, err := repoFromPath(h.c.Repo.ScanPath, data.RepoPath)
+	if err != nil {
+		l.Error("resolving repo", "path", data.RepoPath, "error", err)
+		writeError(w, "unknown repository", http.StatusBadRequest)
+		return
+	}
+
+	if err := recordPush(h.c, h.db, h.l, repo, data.Pusher, data.Updates); err != nil {
+		l.Error("recording ref update", "error", err)
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// repoFromPath returns the did/name of the repository at path, which must
+// live under scanPath.
+func repoFromPath(scanPath, path string) (string, error) {
+	scanPath, err := filepath.EvalSymlinks(scanPath)
+	if err != nil {
+		return "", err
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(scanPath, path)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." || strings.HasPrefix(rel, "..") || strings.Count(rel, string(filepath.Separator)) != 1 {
+		return "", fmt.Errorf("%s is not a repository under %s", path, scanPath)
+	}
+
+	return rel, nil
+}
+
+func Internal(ctx context.Context, c *config.Config, db *db.DB, e *rbac.Enforcer, l *slog.Logger) http.Handler {
 	r := chi.NewRouter()
 
 	h := InternalHandle{
+		c,
 		db,
 		e,
+		l,
 	}
 
 	r.Get("/push-allowed", h.PushAllowed)
+	r.Get("/read-allowed", h.ReadAllowed)
+	r.Get("/mirror", h.Mirror)
 	r.Get("/keys", h.InternalKeys)
+	r.Post("/hooks/pre-receive", h.PreReceive)
+	r.Post("/hooks/post-receive", h.PostReceive)
 	r.Mount("/debug", middleware.Profiler())
 
 	return r
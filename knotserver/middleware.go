@@ -1,11 +1,20 @@
 package knotserver
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/go-chi/chi/v5"
 )
 
 func (h *Handle) VerifySignature(next http.Handler) http.Handler {
@@ -51,3 +60,138 @@ func (h *Handle) verifyHMAC(signature string, r *http.Request) bool {
 
 	return hmac.Equal(signatureBytes, expectedMAC)
 }
+
+// VerifyRead checks read access to private repos. Public repos are always
+// readable. Requests signed by the appview are trusted, as the appview checks
+// read access itself. Anything else needs app password credentials, like
+// VerifyPush.
+func (h *Handle) VerifyRead(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		repo, _ := securejoin.SecureJoin(chi.URLParam(r, "did"), chi.URLParam(r, "name"))
+
+		if ok, _ := h.e.IsReadAllowed("", ThisServer, repo); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if signature := r.Header.Get("X-Signature"); signature != "" && h.verifyHMAC(signature, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, ok := h.authenticate(w, r)
+		if !ok {
+			return
+		}
+
+		if ok, err := h.e.IsReadAllowed(user, ThisServer, repo); err != nil || !ok {
+			notFound(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// VerifyPush authenticates HTTP pushes using basic auth, where the username
+// is a handle or DID and the password is an app password issued by the
+// appview. The authenticated DID is stored in the request context under
+// "pusher".
+func (h *Handle) VerifyPush(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pusher, ok := h.authenticate(w, r)
+		if !ok {
+			return
+		}
+
+		repo, _ := securejoin.SecureJoin(chi.URLParam(r, "did"), chi.URLParam(r, "name"))
+		ok, err := h.e.IsPushAllowed(pusher, ThisServer, repo)
+		if err != nil || !ok {
+			writeError(w, "push not allowed", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "pusher", pusher)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticate returns the DID of the user identified by the request's app
+// password credentials. Otherwise, it asks the client for credentials and
+// returns false.
+func (h *Handle) authenticate(w http.ResponseWriter, r *http.Request) (string, bool) {
+	user, password, ok := parseBasicAuth(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="tangled", charset="UTF-8"`)
+		writeError(w, "authentication required", http.StatusUnauthorized)
+		return "", false
+	}
+
+	did, err := h.verifyAppPassword(r.Context(), user, password)
+	if err != nil {
+		h.l.Error("verifying app password", "user", user, "error", err)
+		w.Header().Set("WWW-Authenticate", `Basic realm="tangled", charset="UTF-8"`)
+		writeError(w, "invalid credentials", http.StatusUnauthorized)
+		return "", false
+	}
+
+	return did, true
+}
+
+// parseBasicAuth is like http.Request.BasicAuth, but splits on the last
+// colon, since DIDs contain colons and app passwords never do.
+func parseBasicAuth(r *http.Request) (string, string, bool) {
+	auth := r.Header.Get("Authorization")
+	const prefix = "Basic "
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(auth[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+
+	i := strings.LastIndex(string(decoded), ":")
+	if i < 0 {
+		return "", "", false
+	}
+
+	return string(decoded[:i]), string(decoded[i+1:]), true
+}
+
+func (h *Handle) verifyAppPassword(ctx context.Context, user, password string) (string, error) {
+	endpoint, err := url.JoinPath(h.c.AppViewEndpoint, "app-passwords", "verify")
+	if err != nil {
+		return "", fmt.Errorf("error building endpoint url: %w", err)
+	}
+
+	form := url.Values{}
+	form.Set("user", user)
+	form.Set("password", password)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error verifying app password: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("appview rejected credentials: %s", resp.Status)
+	}
+
+	var data struct {
+		Did string `json:"did"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return data.Did, nil
+}
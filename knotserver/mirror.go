@@ -0,0 +1,214 @@
+package knotserver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/sotangled/tangled/knotserver/git"
+	"github.com/sotangled/tangled/knotserver/hook"
+	"github.com/sotangled/tangled/types"
+)
+
+const (
+	// minutes between syncs of a mirror, unless configured otherwise
+	defaultMirrorInterval = 60
+	// syncing more often than this is unkind to upstreams
+	minMirrorInterval = 5
+)
+
+func (h *Handle) Mirror(w http.ResponseWriter, r *http.Request) {
+	repo, _ := securejoin.SecureJoin(chi.URLParam(r, "did"), chi.URLParam(r, "name"))
+
+	m, err := h.db.GetMirror(repo)
+	if errors.Is(err, sql.ErrNoRows) {
+		notFound(w)
+		return
+	}
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// the source may carry credentials for the upstream
+	m.Source = git.RedactUrl(m.Source)
+	writeJSON(w, m)
+}
+
+// SetMirror turns a repo into a mirror of an upstream clone url, and syncs
+// it right away.
+func (h *Handle) SetMirror(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "SetMirror")
+
+	data := struct {
+		Did      string `json:"did"`
+		Name     string `json:"name"`
+		Source   string `json:"source"`
+		Interval int    `json:"interval,omitempty"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.checkImportSource(data.Source); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if data.Interval == 0 {
+		data.Interval = defaultMirrorInterval
+	}
+	if data.Interval < minMirrorInterval {
+		writeError(w, "mirrors can be synced at most every 5 minutes", http.StatusBadRequest)
+		return
+	}
+
+	repo := filepath.Join(data.Did, data.Name)
+	if err := h.db.SetMirror(repo, data.Source, data.Interval); err != nil {
+		l.Error("setting mirror", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	go h.syncMirror(repo, data.Source)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handle) RemoveMirror(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "RemoveMirror")
+
+	data := struct {
+		Did  string `json:"did"`
+		Name string `json:"name"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	repo := filepath.Join(data.Did, data.Name)
+	if err := h.db.RemoveMirror(repo); err != nil {
+		l.Error("removing mirror", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// syncMirrors fetches every mirror that is due, once a minute.
+func (h *Handle) syncMirrors(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		mirrors, err := h.db.GetMirrors()
+		if err != nil {
+			h.l.Error("getting mirrors", "error", err.Error())
+		}
+
+		now := time.Now()
+		for repo, m := range mirrors {
+			if m.Due(now) {
+				// syncMirror skips repos that are still syncing
+				go h.syncMirror(repo, m.Source)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *Handle) syncMirror(repo, source string) {
+	l := h.l.With("handler", "syncMirror", "repo", repo)
+
+	// a sync of this repo is already running
+	if _, running := h.syncing.LoadOrStore(repo, struct{}{}); running {
+		return
+	}
+	defer h.syncing.Delete(repo)
+
+	repoPath, _ := securejoin.SecureJoin(h.c.Repo.ScanPath, repo)
+
+	before, err := git.Refs(repoPath)
+	if err != nil {
+		l.Error("listing refs", "error", err.Error())
+	}
+
+	var syncErr string
+	if err := checkRemote(source, h.c.Server.Dev); err != nil {
+		l.Error("checking mirror source", "error", err.Error())
+		syncErr = err.Error()
+	} else if err := git.Import(repoPath, source, func(string) {}); err != nil {
+		l.Error("syncing mirror", "error", err.Error())
+		syncErr = err.Error()
+	}
+
+	// fetched refs are handled like a push by the repo owner, so that
+	// webhooks and push mirrors see them too
+	if after, err := git.Refs(repoPath); err != nil {
+		l.Error("listing refs", "error", err.Error())
+	} else if updates := refUpdates(before, after); before != nil && len(updates) > 0 {
+		owner, _, _ := strings.Cut(repo, "/")
+		if err := recordPush(h.c, h.db, h.l, repo, owner, updates); err != nil {
+			l.Error("recording mirror updates", "error", err.Error())
+		}
+	}
+
+	if err := h.db.UpdateMirrorSync(repo, time.Now(), syncErr); err != nil {
+		l.Error("recording mirror sync", "error", err.Error())
+	}
+}
+
+// mirrorStatus is the mirror a repo is kept in sync with, if any.
+func (h *Handle) mirrorStatus(repo string) *types.Mirror {
+	m, err := h.db.GetMirror(repo)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			h.l.Error("getting mirror", "repo", repo, "error", err.Error())
+		}
+		return nil
+	}
+	m.Source = git.RedactUrl(m.Source)
+	return m
+}
+
+// refUpdates lists the refs that differ between before and after. Created
+// and deleted refs have the zero hash on the missing side, as in hooks.
+func refUpdates(before, after map[string]string) []hook.RefUpdate {
+	zero := plumbing.ZeroHash.String()
+
+	var updates []hook.RefUpdate
+	for ref, newSha := range after {
+		oldSha, ok := before[ref]
+		if !ok {
+			oldSha = zero
+		}
+		if oldSha != newSha {
+			updates = append(updates, hook.RefUpdate{Ref: ref, OldSha: oldSha, NewSha: newSha})
+		}
+	}
+	for ref, oldSha := range before {
+		if _, ok := after[ref]; !ok {
+			updates = append(updates, hook.RefUpdate{Ref: ref, OldSha: oldSha, NewSha: zero})
+		}
+	}
+
+	return updates
+}
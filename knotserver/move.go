@@ -0,0 +1,85 @@
+package knotserver
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/sotangled/tangled/knotserver/git"
+)
+
+// Bundle streams a git bundle of every ref in the repo, for moving it to
+// another knot. Repos without any refs have nothing to bundle, and get a 204.
+func (h *Handle) Bundle(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "Bundle")
+
+	path, _ := securejoin.SecureJoin(h.c.Repo.ScanPath, didPath(r))
+
+	hasRefs, err := git.HasRefs(path)
+	if err != nil {
+		notFound(w)
+		return
+	}
+	if !hasRefs {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-git-bundle")
+	if err := git.Bundle(path, w); err != nil {
+		// the status line is already out, the caller sees a truncated bundle
+		l.Error("bundling repo", "error", err.Error())
+	}
+}
+
+// ImportBundle creates a repo from a bundle made by Bundle on another knot.
+// The repo is named by the did, name and private query parameters, and the
+// bundle is the request body.
+func (h *Handle) ImportBundle(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "ImportBundle")
+
+	did := r.URL.Query().Get("did")
+	name := r.URL.Query().Get("name")
+	private := r.URL.Query().Get("private") == "true"
+	if did == "" || name == "" {
+		writeError(w, "did and name are required", http.StatusBadRequest)
+		return
+	}
+
+	hooks, err := h.hookConfig()
+	if err != nil {
+		l.Error("building hook config", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	relativeRepoPath := filepath.Join(did, name)
+	repoPath, _ := securejoin.SecureJoin(h.c.Repo.ScanPath, relativeRepoPath)
+	if err := git.Unbundle(repoPath, r.Body, hooks); err != nil {
+		if errors.Is(err, gogit.ErrRepositoryAlreadyExists) {
+			writeError(w, "That repo already exists!", http.StatusConflict)
+			return
+		}
+		l.Error("unbundling repo", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.e.AddRepo(did, ThisServer, relativeRepoPath); err != nil {
+		l.Error("adding repo permissions", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !private {
+		if err := h.e.MakeRepoPublic(ThisServer, relativeRepoPath); err != nil {
+			l.Error("making repo public", "error", err.Error())
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
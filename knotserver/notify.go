@@ -0,0 +1,70 @@
+package knotserver
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sotangled/tangled/knotserver/config"
+	"github.com/sotangled/tangled/knotserver/hook"
+)
+
+// notifyPush reports ref updates to the appview, which uses them to deliver
+// push webhooks. Requests are signed with the knot secret, the same way the
+// appview signs requests to the knot.
+func notifyPush(c *config.Config, repo, pusher string, updates []hook.RefUpdate) error {
+	did, name, ok := strings.Cut(repo, "/")
+	if !ok {
+		return fmt.Errorf("malformed repo: %s", repo)
+	}
+
+	endpoint, err := url.JoinPath(c.AppViewEndpoint, "knot-events", c.Server.Hostname, "push")
+	if err != nil {
+		return fmt.Errorf("error building endpoint url: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"did":     did,
+		"name":    name,
+		"pusher":  pusher,
+		"updates": updates,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signRequest(c.Server.Secret, req)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("appview returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+func signRequest(secret string, req *http.Request) {
+	timestamp := time.Now().Format(time.RFC3339)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(req.Method + req.URL.Path + timestamp))
+	req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set("X-Timestamp", timestamp)
+}
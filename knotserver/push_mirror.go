@@ -0,0 +1,225 @@
+package knotserver
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/go-chi/chi/v5"
+	"github.com/sotangled/tangled/knotserver/config"
+	"github.com/sotangled/tangled/knotserver/db"
+	"github.com/sotangled/tangled/knotserver/git"
+	"github.com/sotangled/tangled/types"
+)
+
+func (h *Handle) PushMirrors(w http.ResponseWriter, r *http.Request) {
+	repo, _ := securejoin.SecureJoin(chi.URLParam(r, "did"), chi.URLParam(r, "name"))
+
+	mirrors, err := h.db.GetPushMirrors(repo)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := types.PushMirrorsResponse{}
+	for _, m := range mirrors {
+		// credentials typed into the url are as secret as the password
+		m.Url = git.RedactUrl(m.Url)
+		resp.Mirrors = append(resp.Mirrors, m.PushMirror)
+	}
+
+	writeJSON(w, resp)
+}
+
+// AddPushMirror adds a remote that the repo is pushed to after every push,
+// and pushes to it right away.
+func (h *Handle) AddPushMirror(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "AddPushMirror")
+
+	data := struct {
+		Did      string `json:"did"`
+		Name     string `json:"name"`
+		Url      string `json:"url"`
+		Username string `json:"username,omitempty"`
+		Password string `json:"password,omitempty"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.checkImportSource(data.Url); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var sealed string
+	if data.Password != "" {
+		var err error
+		sealed, err = sealCredential(h.c.Server.Secret, data.Password)
+		if err != nil {
+			l.Error("sealing password", "error", err.Error())
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	repo := filepath.Join(data.Did, data.Name)
+	if err := h.db.AddPushMirror(repo, data.Url, data.Username, sealed); err != nil {
+		l.Error("adding push mirror", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	go pushMirrors(h.c, h.db, h.l, repo)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handle) RemovePushMirror(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "RemovePushMirror")
+
+	data := struct {
+		Did  string `json:"did"`
+		Name string `json:"name"`
+		Id   int64  `json:"id"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	repo := filepath.Join(data.Did, data.Name)
+	if err := h.db.RemovePushMirror(repo, data.Id); err != nil {
+		l.Error("removing push mirror", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pushMirrorLocks holds a mutex per repo, so that pushes to its mirrors
+// happen one at a time, in order.
+var pushMirrorLocks sync.Map
+
+// pushMirrors pushes every ref of repo to each of its push mirrors.
+func pushMirrors(c *config.Config, d *db.DB, l *slog.Logger, repo string) {
+	l = l.With("handler", "pushMirrors", "repo", repo)
+
+	mu, _ := pushMirrorLocks.LoadOrStore(repo, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+
+	mirrors, err := d.GetPushMirrors(repo)
+	if err != nil {
+		l.Error("getting push mirrors", "error", err.Error())
+		return
+	}
+
+	repoPath, _ := securejoin.SecureJoin(c.Repo.ScanPath, repo)
+	for _, m := range mirrors {
+		var pushErr string
+		if err := pushMirror(c, repoPath, m); err != nil {
+			l.Error("pushing to mirror", "url", git.RedactUrl(m.Url), "error", err.Error())
+			pushErr = err.Error()
+		}
+
+		if err := d.UpdatePushMirrorStatus(m.Id, time.Now(), pushErr); err != nil {
+			l.Error("recording push mirror status", "error", err.Error())
+		}
+	}
+}
+
+func pushMirror(c *config.Config, repoPath string, m db.PushMirror) error {
+	if err := checkRemote(m.Url, c.Server.Dev); err != nil {
+		return err
+	}
+
+	var env []string
+	if m.Password != "" {
+		password, err := openCredential(c.Server.Secret, m.Password)
+		if err != nil {
+			return err
+		}
+
+		// passed through the environment to keep it out of process listings
+		auth := base64.StdEncoding.EncodeToString([]byte(m.Username + ":" + password))
+		env = append(env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+auth,
+		)
+	}
+
+	return git.PushMirror(repoPath, m.Url, env)
+}
+
+// credentialKey derives the key push mirror credentials are sealed with from
+// the knot secret, which is also used to sign requests.
+func credentialKey(secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("push mirror credentials"))
+	return mac.Sum(nil)
+}
+
+// sealCredential encrypts plaintext with AES-GCM.
+func sealCredential(secret, plaintext string) (string, error) {
+	block, err := aes.NewCipher(credentialKey(secret))
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func openCredential(secret, sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(credentialKey(secret))
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("sealed credential is too short")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("failed to unseal credential, has the knot secret changed?")
+	}
+
+	return string(plaintext), nil
+}
@@ -8,12 +8,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/gliderlabs/ssh"
@@ -21,9 +24,13 @@ import (
 	gogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/klauspost/compress/zstd"
 	"github.com/sotangled/tangled/knotserver/db"
 	"github.com/sotangled/tangled/knotserver/git"
+	"github.com/sotangled/tangled/knotserver/hook"
+	"github.com/sotangled/tangled/rbac"
 	"github.com/sotangled/tangled/types"
+	"github.com/ulikunitz/xz"
 )
 
 func (h *Handle) Index(w http.ResponseWriter, r *http.Request) {
@@ -41,6 +48,7 @@ func (h *Handle) RepoIndex(w http.ResponseWriter, r *http.Request) {
 		if errors.Is(err, plumbing.ErrReferenceNotFound) {
 			resp := types.RepoIndexResponse{
 				IsEmpty: true,
+				Mirror:  h.mirrorStatus(didPath(r)),
 			}
 			writeJSON(w, resp)
 			return
@@ -51,15 +59,18 @@ func (h *Handle) RepoIndex(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	commits, err := gr.Commits()
-	total := len(commits)
+	commits, err := gr.Commits(0, 10)
 	if err != nil {
 		writeError(w, err.Error(), http.StatusInternalServerError)
 		l.Error("fetching commits", "error", err.Error())
 		return
 	}
-	if len(commits) > 10 {
-		commits = commits[:10]
+
+	total, err := gr.TotalCommits()
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		l.Error("counting commits", "error", err.Error())
+		return
 	}
 
 	branches, err := gr.Branches()
@@ -139,6 +150,7 @@ func (h *Handle) RepoIndex(w http.ResponseWriter, r *http.Request) {
 		Branches:       bs,
 		Tags:           rtags,
 		TotalCommits:   total,
+		Mirror:         h.mirrorStatus(didPath(r)),
 	}
 
 	writeJSON(w, resp)
@@ -217,25 +229,52 @@ func (h *Handle) Blob(w http.ResponseWriter, r *http.Request) {
 	h.showFile(resp, w, l)
 }
 
-func (h *Handle) Archive(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	file := chi.URLParam(r, "file")
+func (h *Handle) Raw(w http.ResponseWriter, r *http.Request) {
+	treePath := chi.URLParam(r, "*")
+	ref := chi.URLParam(r, "ref")
 
-	l := h.l.With("handler", "Archive", "name", name, "file", file)
+	path, _ := securejoin.SecureJoin(h.c.Repo.ScanPath, didPath(r))
+	gr, err := git.Open(path, ref)
+	if err != nil {
+		notFound(w)
+		return
+	}
 
-	// TODO: extend this to add more files compression (e.g.: xz)
-	if !strings.HasSuffix(file, ".tar.gz") {
+	blob, err := gr.RawFile(treePath)
+	if errors.Is(err, object.ErrFileNotFound) {
 		notFound(w)
 		return
+	} else if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+
+	contentType := mime.TypeByExtension(filepath.Ext(treePath))
+	if contentType == "" {
+		buf := make([]byte, 512)
+		n, _ := io.ReadFull(blob, buf)
+		contentType = http.DetectContentType(buf[:n])
+		blob.Seek(0, io.SeekStart)
+	}
+	// never let a repo serve markup or scripts as a page of its own
+	if strings.HasPrefix(contentType, "text/") || strings.Contains(contentType, "javascript") {
+		contentType = "text/plain; charset=utf-8"
 	}
 
-	ref := strings.TrimSuffix(file, ".tar.gz")
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	w.Header().Set("ETag", fmt.Sprintf("%q", blob.Hash()))
 
-	// This allows the browser to use a proper name for the file when
-	// downloading
-	filename := fmt.Sprintf("%s-%s.tar.gz", name, ref)
-	setContentDisposition(w, filename)
-	setGZipMIME(w)
+	http.ServeContent(w, r, filepath.Base(treePath), time.Time{}, blob)
+}
+
+func (h *Handle) Blame(w http.ResponseWriter, r *http.Request) {
+	treePath := chi.URLParam(r, "*")
+	ref := chi.URLParam(r, "ref")
+
+	l := h.l.With("handler", "Blame", "ref", ref, "treePath", treePath)
 
 	path, _ := securejoin.SecureJoin(h.c.Repo.ScanPath, didPath(r))
 	gr, err := git.Open(path, ref)
@@ -244,23 +283,118 @@ func (h *Handle) Archive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	gw := gzip.NewWriter(w)
-	defer gw.Close()
+	_, err = gr.FileContent(treePath)
+	if errors.Is(err, git.ErrBinaryFile) {
+		writeError(w, "cannot blame a binary file", http.StatusBadRequest)
+		return
+	} else if errors.Is(err, object.ErrFileNotFound) {
+		notFound(w)
+		return
+	} else if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	prefix := fmt.Sprintf("%s-%s", name, ref)
-	err = gr.WriteTar(gw, prefix)
+	lines, err := gr.Blame(treePath)
 	if err != nil {
-		// once we start writing to the body we can't report error anymore
-		// so we are only left with printing the error.
-		l.Error("writing tar file", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		l.Error("blaming file", "error", err.Error())
 		return
 	}
 
-	err = gw.Flush()
+	writeJSON(w, types.RepoBlameResponse{
+		Ref:   ref,
+		Path:  treePath,
+		Lines: lines,
+	})
+}
+
+var archiveFormats = []struct {
+	ext  string
+	mime string
+}{
+	{".tar.gz", "application/gzip"},
+	{".tar.xz", "application/x-xz"},
+	{".tar.zst", "application/zstd"},
+	{".zip", "application/zip"},
+}
+
+func (h *Handle) Archive(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	file := chi.URLParam(r, "file")
+	subdir := r.URL.Query().Get("path")
+
+	l := h.l.With("handler", "Archive", "name", name, "file", file, "subdir", subdir)
+
+	var ref, ext, mime string
+	for _, f := range archiveFormats {
+		if strings.HasSuffix(file, f.ext) {
+			ref = strings.TrimSuffix(file, f.ext)
+			ext, mime = f.ext, f.mime
+			break
+		}
+	}
+	if ext == "" {
+		notFound(w)
+		return
+	}
+
+	path, _ := securejoin.SecureJoin(h.c.Repo.ScanPath, didPath(r))
+	gr, err := git.Open(path, ref)
+	if err != nil {
+		notFound(w)
+		return
+	}
+
+	if subdir != "" && !gr.IsDir(subdir) {
+		notFound(w)
+		return
+	}
+
+	prefix := fmt.Sprintf("%s-%s", name, ref)
+	if subdir != "" {
+		prefix = fmt.Sprintf("%s-%s", prefix, strings.ReplaceAll(strings.Trim(subdir, "/"), "/", "-"))
+	}
+
+	// This allows the browser to use a proper name for the file when
+	// downloading
+	setContentDisposition(w, prefix+ext)
+	setMIME(w, mime)
+
+	switch ext {
+	case ".zip":
+		err = gr.WriteZip(w, prefix, subdir)
+	case ".tar.gz":
+		gw := gzip.NewWriter(w)
+		err = gr.WriteTar(gw, prefix, subdir)
+		if err == nil {
+			err = gw.Close()
+		}
+	case ".tar.xz":
+		xw, xerr := xz.NewWriter(w)
+		if xerr != nil {
+			l.Error("creating xz writer", "error", xerr.Error())
+			return
+		}
+		err = gr.WriteTar(xw, prefix, subdir)
+		if err == nil {
+			err = xw.Close()
+		}
+	case ".tar.zst":
+		zw, zerr := zstd.NewWriter(w)
+		if zerr != nil {
+			l.Error("creating zstd writer", "error", zerr.Error())
+			return
+		}
+		err = gr.WriteTar(zw, prefix, subdir)
+		if err == nil {
+			err = zw.Close()
+		}
+	}
 	if err != nil {
 		// once we start writing to the body we can't report error anymore
 		// so we are only left with printing the error.
-		l.Error("flushing?", "error", err.Error())
+		l.Error("writing archive", "error", err.Error())
 		return
 	}
 }
@@ -277,14 +411,63 @@ func (h *Handle) Log(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	commits, err := gr.Commits()
+	// Get page parameters
+	page := 1
+	pageSize := 30
+
+	if pageParam := r.URL.Query().Get("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if pageSizeParam := r.URL.Query().Get("per_page"); pageSizeParam != "" {
+		if ps, err := strconv.Atoi(pageSizeParam); err == nil && ps > 0 {
+			pageSize = ps
+		}
+	}
+
+	commits, err := gr.Commits((page-1)*pageSize, pageSize)
 	if err != nil {
 		writeError(w, err.Error(), http.StatusInternalServerError)
 		l.Error("fetching commits", "error", err.Error())
 		return
 	}
 
-	// Get page parameters
+	total, err := gr.TotalCommits()
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		l.Error("counting commits", "error", err.Error())
+		return
+	}
+
+	resp := types.RepoLogResponse{
+		Commits:     commits,
+		Ref:         ref,
+		Description: getDescription(path),
+		Log:         true,
+		Total:       total,
+		Page:        page,
+		PerPage:     pageSize,
+	}
+
+	writeJSON(w, resp)
+	return
+}
+
+func (h *Handle) History(w http.ResponseWriter, r *http.Request) {
+	ref := chi.URLParam(r, "ref")
+	treePath := chi.URLParam(r, "*")
+	path, _ := securejoin.SecureJoin(h.c.Repo.ScanPath, didPath(r))
+
+	l := h.l.With("handler", "History", "ref", ref, "treePath", treePath)
+
+	gr, err := git.Open(path, ref)
+	if err != nil {
+		notFound(w)
+		return
+	}
+
 	page := 1
 	pageSize := 30
 
@@ -300,32 +483,24 @@ func (h *Handle) Log(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Calculate pagination
-	start := (page - 1) * pageSize
-	end := start + pageSize
-	total := len(commits)
-
-	if start >= total {
-		commits = []*object.Commit{}
-	} else {
-		if end > total {
-			end = total
-		}
-		commits = commits[start:end]
+	commits, err := gr.FileHistory(treePath, (page-1)*pageSize, pageSize)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		l.Error("fetching file history", "error", err.Error())
+		return
 	}
 
 	resp := types.RepoLogResponse{
 		Commits:     commits,
 		Ref:         ref,
+		Path:        treePath,
 		Description: getDescription(path),
 		Log:         true,
-		Total:       total,
 		Page:        page,
 		PerPage:     pageSize,
 	}
 
 	writeJSON(w, resp)
-	return
 }
 
 func (h *Handle) Diff(w http.ResponseWriter, r *http.Request) {
@@ -356,6 +531,41 @@ func (h *Handle) Diff(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+func (h *Handle) Compare(w http.ResponseWriter, r *http.Request) {
+	rest := chi.URLParam(r, "*")
+
+	l := h.l.With("handler", "Compare", "range", rest)
+
+	base, head, ok := strings.Cut(rest, "...")
+	if !ok || base == "" || head == "" {
+		writeError(w, "expected a range of the form base...head", http.StatusBadRequest)
+		return
+	}
+
+	path, _ := securejoin.SecureJoin(h.c.Repo.ScanPath, didPath(r))
+	gr, err := git.Open(path, head)
+	if err != nil {
+		notFound(w)
+		return
+	}
+
+	resp, err := gr.Compare(base)
+	if err != nil {
+		if errors.Is(err, git.ErrNoMergeBase) {
+			writeError(w, fmt.Sprintf("%s and %s have no common history", base, head), http.StatusUnprocessableEntity)
+			return
+		}
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		l.Error("comparing refs", "error", err.Error())
+		return
+	}
+
+	resp.Base = base
+	resp.Head = head
+
+	writeJSON(w, resp)
+}
+
 func (h *Handle) Tags(w http.ResponseWriter, r *http.Request) {
 	path, _ := securejoin.SecureJoin(h.c.Repo.ScanPath, didPath(r))
 	l := h.l.With("handler", "Refs")
@@ -481,6 +691,7 @@ func (h *Handle) NewRepo(w http.ResponseWriter, r *http.Request) {
 		Did           string `json:"did"`
 		Name          string `json:"name"`
 		DefaultBranch string `json:"default_branch,omitempty"`
+		Private       bool   `json:"private,omitempty"`
 	}{}
 
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
@@ -497,9 +708,16 @@ func (h *Handle) NewRepo(w http.ResponseWriter, r *http.Request) {
 	name := data.Name
 	defaultBranch := data.DefaultBranch
 
+	hooks, err := h.hookConfig()
+	if err != nil {
+		l.Error("building hook config", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	relativeRepoPath := filepath.Join(did, name)
 	repoPath, _ := securejoin.SecureJoin(h.c.Repo.ScanPath, relativeRepoPath)
-	err := git.InitBare(repoPath, defaultBranch)
+	err = git.InitBare(repoPath, defaultBranch, hooks)
 	if err != nil {
 		l.Error("initializing bare repo", "error", err.Error())
 		if errors.Is(err, gogit.ErrRepositoryAlreadyExists) {
@@ -519,9 +737,42 @@ func (h *Handle) NewRepo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !data.Private {
+		if err := h.e.MakeRepoPublic(ThisServer, relativeRepoPath); err != nil {
+			l.Error("making repo public", "error", err.Error())
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (h *Handle) ForkRepo(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "ForkRepo")
+
+	data := struct {
+		Did    string `json:"did"`
+		Name   string `json:"name"`
+		Source string `json:"source"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if data.Did == "" || data.Name == "" || data.Source == "" {
+		writeError(w, "did, name and source are required", http.StatusBadRequest)
+		return
+	}
+
+	// cloning can take a while, so forks go through the import machinery
+	// and can be followed with ImportStatus. Only public repos can be
+	// forked, so forks are public too.
+	h.startImport(w, l, data.Did, data.Name, data.Source, false, false)
+}
+
 func (h *Handle) RemoveRepo(w http.ResponseWriter, r *http.Request) {
 	l := h.l.With("handler", "RemoveRepo")
 
@@ -553,9 +804,230 @@ func (h *Handle) RemoveRepo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.db.RemoveRepo(relativeRepoPath); err != nil {
+		l.Error("removing repo data", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.e.RemoveRepo(ThisServer, relativeRepoPath); err != nil {
+		l.Error("removing repo policies", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RenameRepo moves a repo to a new name, a new owner, or both.
+func (h *Handle) RenameRepo(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "RenameRepo")
+
+	data := struct {
+		Did     string `json:"did"`
+		Name    string `json:"name"`
+		NewDid  string `json:"new_did"`
+		NewName string `json:"new_name"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if data.Did == "" || data.Name == "" || data.NewDid == "" || data.NewName == "" {
+		writeError(w, "did, name, new_did and new_name are required", http.StatusBadRequest)
+		return
+	}
+
+	oldRepo := filepath.Join(data.Did, data.Name)
+	newRepo := filepath.Join(data.NewDid, data.NewName)
+	oldPath, _ := securejoin.SecureJoin(h.c.Repo.ScanPath, oldRepo)
+	newPath, _ := securejoin.SecureJoin(h.c.Repo.ScanPath, newRepo)
+
+	if _, err := os.Stat(oldPath); err != nil {
+		notFound(w)
+		return
+	}
+	if _, err := os.Stat(newPath); err == nil {
+		writeError(w, "That repo already exists!", http.StatusConflict)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		l.Error("creating owner directory", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := os.Rename(oldPath, newPath); err != nil {
+		l.Error("moving repo", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.db.RenameRepo(oldRepo, newRepo); err != nil {
+		l.Error("renaming repo data", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.e.RenameRepo(ThisServer, oldRepo, newRepo); err != nil {
+		l.Error("renaming repo policies", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// the new owner needs their keys here to push over ssh
+	if data.NewDid != data.Did {
+		if err := h.db.AddDid(data.NewDid); err != nil {
+			l.Error("adding did", "error", err.Error())
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.jc.AddDid(data.NewDid)
+
+		if err := h.fetchAndAddKeys(r.Context(), data.NewDid); err != nil {
+			l.Error("fetching and adding keys", "error", err.Error())
+		}
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (h *Handle) MergeCheck(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "MergeCheck")
+
+	data := struct {
+		Did    string `json:"did"`
+		Name   string `json:"name"`
+		Patch  string `json:"patch"`
+		Branch string `json:"branch"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if data.Did == "" || data.Name == "" || data.Patch == "" || data.Branch == "" {
+		writeError(w, "did, name, patch and branch are required", http.StatusBadRequest)
+		return
+	}
+
+	relativeRepoPath := filepath.Join(data.Did, data.Name)
+	repoPath, _ := securejoin.SecureJoin(h.c.Repo.ScanPath, relativeRepoPath)
+	gr, err := git.Open(repoPath, data.Branch)
+	if err != nil {
+		notFound(w)
+		return
+	}
+
+	resp := types.MergeCheckResponse{}
+
+	err = gr.MergeCheck([]byte(data.Patch))
+	var mergeErr git.ErrMerge
+	switch {
+	case err == nil:
+		resp.Message = "patch applies cleanly"
+	case errors.As(err, &mergeErr):
+		resp.IsConflicted = true
+		resp.Conflicts = mergeErr.Conflicts
+		resp.Message = mergeErr.Message
+	default:
+		l.Error("checking patch", "error", err.Error())
+		resp.IsConflicted = true
+		resp.Error = err.Error()
+	}
+
+	writeJSON(w, resp)
+}
+
+func (h *Handle) Merge(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "Merge")
+
+	data := struct {
+		Did            string `json:"did"`
+		Name           string `json:"name"`
+		Patch          string `json:"patch"`
+		Branch         string `json:"branch"`
+		AuthorName     string `json:"authorName"`
+		AuthorEmail    string `json:"authorEmail"`
+		CommitterName  string `json:"committerName"`
+		CommitterEmail string `json:"committerEmail"`
+		CommitMessage  string `json:"commitMessage"`
+		NoFastForward  bool   `json:"noFastForward"`
+		Pusher         string `json:"pusher"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if data.Did == "" || data.Name == "" || data.Patch == "" || data.Branch == "" {
+		writeError(w, "did, name, patch and branch are required", http.StatusBadRequest)
+		return
+	}
+
+	relativeRepoPath := filepath.Join(data.Did, data.Name)
+	repoPath, _ := securejoin.SecureJoin(h.c.Repo.ScanPath, relativeRepoPath)
+	gr, err := git.Open(repoPath, data.Branch)
+	if err != nil {
+		notFound(w)
+		return
+	}
+
+	// merges skip the git hooks, so apply the same checks and follow-up
+	// as a push to the branch would get
+	var update hook.RefUpdate
+	head, err := gr.Merge([]byte(data.Patch), data.Branch, git.MergeOptions{
+		AuthorName:     data.AuthorName,
+		AuthorEmail:    data.AuthorEmail,
+		CommitterName:  data.CommitterName,
+		CommitterEmail: data.CommitterEmail,
+		CommitMessage:  data.CommitMessage,
+		NoFastForward:  data.NoFastForward,
+		BeforeUpdate: func(old, new string) error {
+			update = hook.RefUpdate{
+				OldSha: old,
+				NewSha: new,
+				Ref:    "refs/heads/" + data.Branch,
+			}
+			return checkPush(h.db, h.e, relativeRepoPath, data.Pusher, []hook.RefUpdate{update})
+		},
+	})
+	var mergeErr git.ErrMerge
+	var rejected pushRejected
+	switch {
+	case err == nil:
+	case errors.As(err, &mergeErr):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(types.MergeCheckResponse{
+			IsConflicted: true,
+			Conflicts:    mergeErr.Conflicts,
+			Message:      mergeErr.Message,
+		})
+		return
+	case errors.As(err, &rejected):
+		l.Info("merge rejected", "repo", relativeRepoPath, "pusher", data.Pusher, "reason", err)
+		writeError(w, err.Error(), http.StatusForbidden)
+		return
+	default:
+		l.Error("merging patch", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := recordPush(h.c, h.db, h.l, relativeRepoPath, data.Pusher, []hook.RefUpdate{update}); err != nil {
+		// the branch has moved already, so only log
+		l.Error("recording ref update", "error", err.Error())
+	}
+
+	writeJSON(w, types.MergeResponse{Hash: head})
+}
+
 func (h *Handle) AddMember(w http.ResponseWriter, r *http.Request) {
 	l := h.l.With("handler", "AddMember")
 
@@ -592,6 +1064,49 @@ func (h *Handle) AddMember(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// RemoveMember revokes did's membership of the knot, their access to
+// other people's repos and their keys.
+func (h *Handle) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "RemoveMember")
+
+	data := struct {
+		Did string `json:"did"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	did := data.Did
+
+	if ok, _ := h.e.IsServerOwner(did, ThisServer); ok {
+		writeError(w, "cannot remove the knot owner", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.e.RemoveMember(ThisServer, did); err != nil {
+		l.Error("removing member", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.db.RemovePublicKey(did); err != nil {
+		l.Error("removing public keys", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.db.RemoveDid(did); err != nil {
+		l.Error("removing did", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.jc.RemoveDid(did)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *Handle) AddRepoCollaborator(w http.ResponseWriter, r *http.Request) {
 	l := h.l.With("handler", "AddRepoCollaborator")
 
@@ -607,6 +1122,17 @@ func (h *Handle) AddRepoCollaborator(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// role changes go through SetRepoCollaboratorRole
+	repoName, _ := securejoin.SecureJoin(ownerDid, repo)
+	if ok, _ := h.e.IsRepoOwner(data.Did, ThisServer, repoName); ok {
+		writeError(w, "the repo owner can't be added as a collaborator", http.StatusBadRequest)
+		return
+	}
+	if h.e.GetCollaboratorRole(data.Did, ThisServer, repoName) != "" {
+		writeError(w, "already a collaborator", http.StatusConflict)
+		return
+	}
+
 	if err := h.db.AddDid(data.Did); err != nil {
 		l.Error("adding did", "error", err.Error())
 		writeError(w, err.Error(), http.StatusInternalServerError)
@@ -614,7 +1140,6 @@ func (h *Handle) AddRepoCollaborator(w http.ResponseWriter, r *http.Request) {
 	}
 	h.jc.AddDid(data.Did)
 
-	repoName, _ := securejoin.SecureJoin(ownerDid, repo)
 	if err := h.e.AddCollaborator(data.Did, ThisServer, repoName); err != nil {
 		l.Error("adding repo collaborator", "error", err.Error())
 		writeError(w, err.Error(), http.StatusInternalServerError)
@@ -630,6 +1155,67 @@ func (h *Handle) AddRepoCollaborator(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (h *Handle) RemoveRepoCollaborator(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "RemoveRepoCollaborator")
+
+	data := struct {
+		Did string `json:"did"`
+	}{}
+
+	ownerDid := chi.URLParam(r, "did")
+	repo := chi.URLParam(r, "name")
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	repoName, _ := securejoin.SecureJoin(ownerDid, repo)
+	if err := h.e.RemoveCollaborator(data.Did, ThisServer, repoName); err != nil {
+		l.Error("removing repo collaborator", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handle) SetRepoCollaboratorRole(w http.ResponseWriter, r *http.Request) {
+	l := h.l.With("handler", "SetRepoCollaboratorRole")
+
+	data := struct {
+		Did  string `json:"did"`
+		Role string `json:"role"`
+	}{}
+
+	ownerDid := chi.URLParam(r, "did")
+	repo := chi.URLParam(r, "name")
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !rbac.IsCollaboratorRole(data.Role) {
+		writeError(w, fmt.Sprintf("invalid role: %s", data.Role), http.StatusBadRequest)
+		return
+	}
+
+	repoName, _ := securejoin.SecureJoin(ownerDid, repo)
+	if h.e.GetCollaboratorRole(data.Did, ThisServer, repoName) == "" {
+		notFound(w)
+		return
+	}
+
+	if err := h.e.SetCollaboratorRole(data.Did, ThisServer, repoName, data.Role); err != nil {
+		l.Error("setting repo collaborator role", "error", err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *Handle) Init(w http.ResponseWriter, r *http.Request) {
 	l := h.l.With("handler", "Init")
 
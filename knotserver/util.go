@@ -36,10 +36,6 @@ func setContentDisposition(w http.ResponseWriter, name string) {
 	w.Header().Add("Content-Disposition", h)
 }
 
-func setGZipMIME(w http.ResponseWriter) {
-	setMIME(w, "application/gzip")
-}
-
 func setMIME(w http.ResponseWriter, mime string) {
 	w.Header().Add("Content-Type", mime)
 }
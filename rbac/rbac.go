@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"path"
+	"slices"
 	"strings"
 
 	adapter "github.com/Blank-Xu/sql-adapter"
@@ -26,7 +27,7 @@ g = _, _, _
 e = some(where (p.eft == allow))
 
 [matchers]
-m = r.act == p.act && r.dom == p.dom && keyMatch2(r.obj, p.obj) && g(r.sub, p.sub, r.dom)
+m = r.act == p.act && r.dom == p.dom && keyMatch2(r.obj, p.obj) && (g(r.sub, p.sub, r.dom) || p.sub == "*")
 `
 )
 
@@ -96,6 +97,26 @@ func (e *Enforcer) AddMember(domain, member string) error {
 	return err
 }
 
+// RemoveMember removes member from domain, along with any access they were
+// given to other people's repos in it. Their own repos are left alone.
+func (e *Enforcer) RemoveMember(domain, member string) error {
+	if _, err := e.E.RemoveFilteredGroupingPolicy(0, member, "", domain); err != nil {
+		return err
+	}
+
+	collaborations, err := e.E.GetFilteredPolicy(0, member, domain, "", "repo:collaborator")
+	if err != nil {
+		return err
+	}
+	for _, p := range collaborations {
+		if err := e.RemoveCollaborator(member, domain, p[2]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (e *Enforcer) AddRepo(member, domain, repo string) error {
 	// sanity check, repo must be of the form ownerDid/repo
 	if parts := strings.SplitN(repo, "/", 2); !strings.HasPrefix(parts[0], "did:") {
@@ -108,25 +129,280 @@ func (e *Enforcer) AddRepo(member, domain, repo string) error {
 		{member, domain, repo, "repo:owner"},
 		{member, domain, repo, "repo:invite"},
 		{member, domain, repo, "repo:delete"},
+		{member, domain, repo, "repo:read"},
+		{member, domain, repo, "repo:triage"},
 		{"server:owner", domain, repo, "repo:delete"}, // server owner can delete any repo
+		{"server:owner", domain, repo, "repo:read"},
 	})
 	return err
 }
 
+// RemoveRepo removes every policy on repo, for anyone.
+func (e *Enforcer) RemoveRepo(domain, repo string) error {
+	_, err := e.E.RemoveFilteredPolicy(1, domain, repo)
+	return err
+}
+
+// RenameRepo moves every policy on oldRepo over to newRepo. If the owner
+// changes too, the new owner takes over the old owner's permissions, and
+// any access they had as a collaborator is replaced.
+func (e *Enforcer) RenameRepo(domain, oldRepo, newRepo string) error {
+	// sanity check, repo must be of the form ownerDid/repo
+	oldOwner, _, _ := strings.Cut(oldRepo, "/")
+	newOwner, _, _ := strings.Cut(newRepo, "/")
+	if !strings.HasPrefix(oldOwner, "did:") || !strings.HasPrefix(newOwner, "did:") {
+		return fmt.Errorf("invalid repo: %s -> %s", oldRepo, newRepo)
+	}
+
+	policies, err := e.E.GetFilteredPolicy(1, domain, oldRepo)
+	if err != nil {
+		return err
+	}
+
+	var renamed [][]string
+	for _, p := range policies {
+		sub := p[0]
+		if sub == newOwner && oldOwner != newOwner {
+			continue
+		}
+		if sub == oldOwner {
+			sub = newOwner
+		}
+		renamed = append(renamed, []string{sub, domain, newRepo, p[3]})
+	}
+
+	if err := e.RemoveRepo(domain, oldRepo); err != nil {
+		return err
+	}
+	_, err = e.E.AddPolicies(renamed)
+	return err
+}
+
+// MoveRepo carries the policies of repo over from oldDomain to newDomain,
+// for when a repo moves between knots.
+func (e *Enforcer) MoveRepo(oldDomain, newDomain, repo string) error {
+	policies, err := e.E.GetFilteredPolicy(1, oldDomain, repo)
+	if err != nil {
+		return err
+	}
+
+	var moved [][]string
+	for _, p := range policies {
+		moved = append(moved, []string{p[0], newDomain, repo, p[3]})
+	}
+
+	if err := e.RemoveRepo(oldDomain, repo); err != nil {
+		return err
+	}
+	if err := e.RemoveRepo(newDomain, repo); err != nil {
+		return err
+	}
+	_, err = e.E.AddPolicies(moved)
+	return err
+}
+
+// MakeRepoPublic lets anyone, including logged out users, read the repo.
+func (e *Enforcer) MakeRepoPublic(domain, repo string) error {
+	_, err := e.E.AddPolicy("*", domain, repo, "repo:read")
+	return err
+}
+
+// BackfillReadPolicies adds repo:read policies to repos created before the
+// permission existed. Such repos are made public, as they were before.
+func (e *Enforcer) BackfillReadPolicies() error {
+	owners, err := e.E.GetFilteredPolicy(3, "repo:owner")
+	if err != nil {
+		return err
+	}
+	for _, p := range owners {
+		member, domain, repo := p[0], p[1], p[2]
+		existing, err := e.E.GetFilteredPolicy(1, domain, repo, "repo:read")
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			continue
+		}
+		_, err = e.E.AddPolicies([][]string{
+			{member, domain, repo, "repo:read"},
+			{"server:owner", domain, repo, "repo:read"},
+			{"*", domain, repo, "repo:read"},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	collaborators, err := e.E.GetFilteredPolicy(3, "repo:collaborator")
+	if err != nil {
+		return err
+	}
+	for _, p := range collaborators {
+		if _, err := e.E.AddPolicy(p[0], p[1], p[2], "repo:read"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Collaborator roles, from least to most access. Each role has all the
+// permissions of the ones before it.
+const (
+	// read: clone and browse private repos
+	CollaboratorRead = "read"
+	// triage: also close and reopen issues and pulls
+	CollaboratorTriage = "triage"
+	// write: also push
+	CollaboratorWrite = "write"
+	// maintain: also manage settings and collaborators
+	CollaboratorMaintain = "maintain"
+	// admin: also delete the repo
+	CollaboratorAdmin = "admin"
+)
+
+var CollaboratorRoles = []string{
+	CollaboratorRead,
+	CollaboratorTriage,
+	CollaboratorWrite,
+	CollaboratorMaintain,
+	CollaboratorAdmin,
+}
+
+var collaboratorPermissions = map[string][]string{
+	CollaboratorRead:     {"repo:read"},
+	CollaboratorTriage:   {"repo:read", "repo:triage"},
+	CollaboratorWrite:    {"repo:read", "repo:triage", "repo:push"},
+	CollaboratorMaintain: {"repo:read", "repo:triage", "repo:push", "repo:settings", "repo:invite"},
+	CollaboratorAdmin:    {"repo:read", "repo:triage", "repo:push", "repo:settings", "repo:invite", "repo:delete"},
+}
+
+func IsCollaboratorRole(role string) bool {
+	_, ok := collaboratorPermissions[role]
+	return ok
+}
+
+// BackfillRoles moves owners and collaborators added before roles existed
+// onto them. Old collaborators could push and change settings, which is now
+// closest to write.
+func (e *Enforcer) BackfillRoles() error {
+	owners, err := e.E.GetFilteredPolicy(3, "repo:owner")
+	if err != nil {
+		return err
+	}
+	for _, p := range owners {
+		if _, err := e.E.AddPolicy(p[0], p[1], p[2], "repo:triage"); err != nil {
+			return err
+		}
+	}
+
+	collaborators, err := e.E.GetFilteredPolicy(3, "repo:collaborator")
+	if err != nil {
+		return err
+	}
+	for _, p := range collaborators {
+		collaborator, domain, repo := p[0], p[1], p[2]
+		permissions := e.GetPermissionsInRepo(collaborator, domain, repo)
+		if slices.Contains(permissions, "repo:triage") || !slices.Contains(permissions, "repo:push") {
+			continue
+		}
+		if err := e.SetCollaboratorRole(collaborator, domain, repo, CollaboratorWrite); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (e *Enforcer) AddCollaborator(collaborator, domain, repo string) error {
+	return e.SetCollaboratorRole(collaborator, domain, repo, CollaboratorWrite)
+}
+
+// SetCollaboratorRole replaces whatever access collaborator has to repo with
+// that of role.
+func (e *Enforcer) SetCollaboratorRole(collaborator, domain, repo, role string) error {
 	// sanity check, repo must be of the form ownerDid/repo
 	if parts := strings.SplitN(repo, "/", 2); !strings.HasPrefix(parts[0], "did:") {
 		return fmt.Errorf("invalid repo: %s", repo)
 	}
 
-	_, err := e.E.AddPolicies([][]string{
-		{collaborator, domain, repo, "repo:collaborator"},
-		{collaborator, domain, repo, "repo:settings"},
-		{collaborator, domain, repo, "repo:push"},
-	})
+	permissions, ok := collaboratorPermissions[role]
+	if !ok {
+		return fmt.Errorf("invalid role: %s", role)
+	}
+
+	if ok, _ := e.IsRepoOwner(collaborator, domain, repo); ok {
+		return fmt.Errorf("%s owns %s and can't be a collaborator", collaborator, repo)
+	}
+
+	if err := e.RemoveCollaborator(collaborator, domain, repo); err != nil {
+		return err
+	}
+
+	policies := [][]string{{collaborator, domain, repo, "repo:collaborator"}}
+	for _, p := range permissions {
+		policies = append(policies, []string{collaborator, domain, repo, p})
+	}
+
+	_, err := e.E.AddPolicies(policies)
 	return err
 }
 
+// RemoveCollaborator takes away the access collaborator was given to repo as
+// a collaborator. Anything else they hold on it, like ownership, is kept.
+func (e *Enforcer) RemoveCollaborator(collaborator, domain, repo string) error {
+	permissions := []string{"repo:collaborator"}
+	if ok, _ := e.IsRepoOwner(collaborator, domain, repo); !ok {
+		// admin holds every permission a collaborator can be given
+		permissions = append(permissions, collaboratorPermissions[CollaboratorAdmin]...)
+	}
+
+	for _, p := range permissions {
+		if _, err := e.E.RemovePolicy(collaborator, domain, repo, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCollaboratorRole returns the role of collaborator in repo, or an empty
+// string if they are not a collaborator.
+func (e *Enforcer) GetCollaboratorRole(collaborator, domain, repo string) string {
+	permissions := e.GetPermissionsInRepo(collaborator, domain, repo)
+	if !slices.Contains(permissions, "repo:collaborator") {
+		return ""
+	}
+
+	role := CollaboratorRead
+	for _, r := range CollaboratorRoles {
+		if hasAll(permissions, collaboratorPermissions[r]) {
+			role = r
+		}
+	}
+	return role
+}
+
+// CanManageRole reports whether user may grant role to, or revoke it from,
+// others in repo. Owners can manage any role, collaborators only their own
+// role and the ones below it.
+func (e *Enforcer) CanManageRole(user, domain, repo, role string) bool {
+	if ok, _ := e.IsRepoOwner(user, domain, repo); ok {
+		return true
+	}
+
+	own := slices.Index(CollaboratorRoles, e.GetCollaboratorRole(user, domain, repo))
+	return own >= 0 && slices.Index(CollaboratorRoles, role) <= own
+}
+
+func hasAll(have, want []string) bool {
+	for _, w := range want {
+		if !slices.Contains(have, w) {
+			return false
+		}
+	}
+	return true
+}
+
 func (e *Enforcer) GetUserByRole(role, domain string) ([]string, error) {
 	var membersWithoutRoles []string
 
@@ -161,6 +437,20 @@ func (e *Enforcer) IsPushAllowed(user, domain, repo string) (bool, error) {
 	return e.E.Enforce(user, domain, repo, "repo:push")
 }
 
+// IsReadAllowed reports whether user may read the repo. Pass an empty user
+// for logged out access.
+func (e *Enforcer) IsReadAllowed(user, domain, repo string) (bool, error) {
+	return e.E.Enforce(user, domain, repo, "repo:read")
+}
+
+func (e *Enforcer) IsTriageAllowed(user, domain, repo string) (bool, error) {
+	return e.E.Enforce(user, domain, repo, "repo:triage")
+}
+
+func (e *Enforcer) IsRepoOwner(user, domain, repo string) (bool, error) {
+	return e.E.Enforce(user, domain, repo, "repo:owner")
+}
+
 func (e *Enforcer) IsSettingsAllowed(user, domain, repo string) (bool, error) {
 	return e.E.Enforce(user, domain, repo, "repo:settings")
 }
@@ -0,0 +1,31 @@
+package types
+
+import (
+	"path"
+	"strings"
+)
+
+// BranchRule protects the branches matching Pattern, a glob such as "main"
+// or "release/*".
+type BranchRule struct {
+	Id          int64  `json:"id"`
+	Pattern     string `json:"pattern"`
+	NoForcePush bool   `json:"no_force_push"`
+	NoDeletion  bool   `json:"no_deletion"`
+	OwnersOnly  bool   `json:"owners_only"`
+}
+
+// Matches reports whether ref, a full ref name, is a branch covered by the
+// rule.
+func (b BranchRule) Matches(ref string) bool {
+	branch, ok := strings.CutPrefix(ref, "refs/heads/")
+	if !ok {
+		return false
+	}
+	matched, _ := path.Match(b.Pattern, branch)
+	return matched
+}
+
+type BranchRulesResponse struct {
+	Rules []BranchRule `json:"rules"`
+}
@@ -0,0 +1,18 @@
+package types
+
+type ImportStatus string
+
+const (
+	ImportRunning   ImportStatus = "running"
+	ImportSucceeded ImportStatus = "succeeded"
+	ImportFailed    ImportStatus = "failed"
+)
+
+// ImportResponse describes the progress of importing a repo from a clone
+// url. Progress is the last line of output from git.
+type ImportResponse struct {
+	Source   string       `json:"source"`
+	Status   ImportStatus `json:"status"`
+	Progress string       `json:"progress,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
@@ -0,0 +1,17 @@
+package types
+
+type ConflictInfo struct {
+	Filename string `json:"filename"`
+	Reason   string `json:"reason"`
+}
+
+type MergeCheckResponse struct {
+	IsConflicted bool           `json:"is_conflicted"`
+	Conflicts    []ConflictInfo `json:"conflicts,omitempty"`
+	Message      string         `json:"message,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+type MergeResponse struct {
+	Hash string `json:"hash"`
+}
@@ -0,0 +1,34 @@
+package types
+
+import "time"
+
+// Mirror is a repo that is periodically fetched from Source, an upstream
+// clone url. Pushes to mirrors are rejected.
+type Mirror struct {
+	Source    string     `json:"source"`
+	Interval  int        `json:"interval"` // minutes between syncs
+	LastSync  *time.Time `json:"last_sync,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// Due reports whether the mirror should be synced at now.
+func (m Mirror) Due(now time.Time) bool {
+	if m.LastSync == nil {
+		return true
+	}
+	return now.Sub(*m.LastSync) >= time.Duration(m.Interval)*time.Minute
+}
+
+// PushMirror is an external remote that every ref of a repo is pushed to
+// after each push to the knot. Its password is never sent out of the knot.
+type PushMirror struct {
+	Id        int64      `json:"id"`
+	Url       string     `json:"url"`
+	Username  string     `json:"username,omitempty"`
+	LastPush  *time.Time `json:"last_push,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+type PushMirrorsResponse struct {
+	Mirrors []PushMirror `json:"mirrors"`
+}
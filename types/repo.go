@@ -1,6 +1,8 @@
 package types
 
 import (
+	"time"
+
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
@@ -15,11 +17,13 @@ type RepoIndexResponse struct {
 	Branches       []Branch         `json:"branches,omitempty"`
 	Tags           []*TagReference  `json:"tags,omitempty"`
 	TotalCommits   int              `json:"total_commits,omitempty"`
+	Mirror         *Mirror          `json:"mirror,omitempty"`
 }
 
 type RepoLogResponse struct {
 	Commits     []*object.Commit `json:"commits,omitempty"`
 	Ref         string           `json:"ref,omitempty"`
+	Path        string           `json:"path,omitempty"`
 	Description string           `json:"description,omitempty"`
 	Log         bool             `json:"log,omitempty"`
 	Total       int              `json:"total,omitempty"`
@@ -32,6 +36,14 @@ type RepoCommitResponse struct {
 	Diff *NiceDiff `json:"diff,omitempty"`
 }
 
+type RepoCompareResponse struct {
+	Base      string           `json:"base,omitempty"`
+	Head      string           `json:"head,omitempty"`
+	MergeBase string           `json:"merge_base,omitempty"`
+	Commits   []*object.Commit `json:"commits,omitempty"`
+	Diff      *NiceDiff        `json:"diff,omitempty"`
+}
+
 type RepoTreeResponse struct {
 	Ref         string     `json:"ref,omitempty"`
 	Parent      string     `json:"parent,omitempty"`
@@ -72,3 +84,19 @@ type RepoBlobResponse struct {
 	Lines    int    `json:"lines,omitempty"`
 	SizeHint uint64 `json:"size_hint,omitempty"`
 }
+
+type BlameLine struct {
+	Hash        string    `json:"hash"`
+	AuthorName  string    `json:"author_name"`
+	AuthorEmail string    `json:"author_email"`
+	AuthorTime  time.Time `json:"author_time"`
+	Summary     string    `json:"summary,omitempty"`
+	LineNo      int       `json:"line_no"`
+	Content     string    `json:"content"`
+}
+
+type RepoBlameResponse struct {
+	Ref   string      `json:"ref,omitempty"`
+	Path  string      `json:"path,omitempty"`
+	Lines []BlameLine `json:"lines,omitempty"`
+}